@@ -225,6 +225,13 @@ var Commands = []cli.Command{
 		Action:          runCommand(cmdCreateOuter),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:        "engine-config",
+		Usage:       "Print the engine config a provisioner would write, without creating a machine",
+		Description: fmt.Sprintf("Run '%s engine-config --provisioner name' with the engine flags of create to preview its config.", os.Args[0]),
+		Action:      runCommand(cmdEngineConfig),
+		Flags:       engineConfigFlags(),
+	},
 	{
 		Name:        "env",
 		Usage:       "Display the commands to set up the environment for the Docker client",
@@ -74,6 +74,24 @@ var (
 			Name:  "engine-storage-driver",
 			Usage: "Specify a storage driver to use with the engine",
 		},
+		cli.StringSliceFlag{
+			Name:  "engine-storage-opt",
+			Usage: "Specify storage driver options to use with the engine",
+			Value: &cli.StringSlice{},
+		},
+		cli.StringFlag{
+			Name:  "engine-provisioner-hint",
+			Usage: "Package manager (apt, yum or zypper) to provision with when the OS is not recognized",
+		},
+		cli.StringFlag{
+			Name:  "engine-mount-flags",
+			Usage: "MountFlags of the engine's systemd unit, empty to leave it out",
+			Value: engine.DefaultMountFlags,
+		},
+		cli.StringFlag{
+			Name:  "engine-daemon-binary",
+			Usage: "Absolute path of the engine binary started by systemd, optionally followed by its daemon subcommand",
+		},
 		cli.StringSliceFlag{
 			Name:  "engine-env",
 			Usage: "Specify environment variables to set in the engine",
@@ -127,11 +145,34 @@ var (
 			Name:  "swarm-experimental",
 			Usage: "Enable Swarm experimental features",
 		},
+		cli.StringFlag{
+			Name:  "swarm-mode",
+			Usage: "Swarm flavour to configure: classic (standalone swarm containers) or swarm-mode",
+			Value: swarm.ModeClassic,
+		},
+		cli.StringFlag{
+			Name:  "swarm-join-token",
+			Usage: "Token used to join a swarm-mode cluster",
+		},
+		cli.StringSliceFlag{
+			Name:  "swarm-manager",
+			Usage: "Address of a swarm-mode manager to join, the next one being tried if it cannot be joined",
+			Value: &cli.StringSlice{},
+		},
 		cli.StringSliceFlag{
 			Name:  "tls-san",
 			Usage: "Support extra SANs for TLS certs",
 			Value: &cli.StringSlice{},
 		},
+		cli.StringFlag{
+			Name:  "tls-key-algorithm",
+			Usage: "Private key algorithm for generated TLS certs (rsa or ecdsa)",
+			Value: "rsa",
+		},
+		cli.IntFlag{
+			Name:  "tls-key-bits",
+			Usage: "RSA key size or ECDSA curve size for generated TLS certs, 0 for the default",
+		},
 	}
 )
 
@@ -181,17 +222,10 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			ServerKeyPath:    filepath.Join(mcndirs.GetMachineDir(), name, "server-key.pem"),
 			StorePath:        filepath.Join(mcndirs.GetMachineDir(), name),
 			ServerCertSANs:   c.StringSlice("tls-san"),
+			KeyAlgorithm:     c.String("tls-key-algorithm"),
+			KeyBits:          c.Int("tls-key-bits"),
 		},
-		EngineOptions: &engine.Options{
-			ArbitraryFlags:   c.StringSlice("engine-opt"),
-			Env:              c.StringSlice("engine-env"),
-			InsecureRegistry: c.StringSlice("engine-insecure-registry"),
-			Labels:           c.StringSlice("engine-label"),
-			RegistryMirror:   c.StringSlice("engine-registry-mirror"),
-			StorageDriver:    c.String("engine-storage-driver"),
-			TLSVerify:        true,
-			InstallURL:       c.String("engine-install-url"),
-		},
+		EngineOptions: engineOptionsFromFlags(c),
 		SwarmOptions: &swarm.Options{
 			IsSwarm:            c.Bool("swarm") || c.Bool("swarm-master"),
 			Image:              c.String("swarm-image"),
@@ -204,6 +238,9 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 			ArbitraryFlags:     c.StringSlice("swarm-opt"),
 			ArbitraryJoinFlags: c.StringSlice("swarm-join-opt"),
 			IsExperimental:     c.Bool("swarm-experimental"),
+			Mode:               c.String("swarm-mode"),
+			JoinToken:          c.String("swarm-join-token"),
+			ManagerAddresses:   c.StringSlice("swarm-manager"),
 		},
 	}
 
@@ -463,3 +500,24 @@ func tlsPath(c CommandLine, flag string, defaultName string) string {
 
 	return filepath.Join(mcndirs.GetMachineCertDir(), defaultName)
 }
+
+// engineOptionsFromFlags returns the engine options set by the engine-*
+// create flags.
+func engineOptionsFromFlags(c CommandLine) *engine.Options {
+	mountFlags := c.String("engine-mount-flags")
+
+	return &engine.Options{
+		ArbitraryFlags:   c.StringSlice("engine-opt"),
+		Env:              c.StringSlice("engine-env"),
+		InsecureRegistry: c.StringSlice("engine-insecure-registry"),
+		Labels:           c.StringSlice("engine-label"),
+		RegistryMirror:   c.StringSlice("engine-registry-mirror"),
+		StorageDriver:    c.String("engine-storage-driver"),
+		StorageOpts:      c.StringSlice("engine-storage-opt"),
+		TLSVerify:        true,
+		InstallURL:       c.String("engine-install-url"),
+		ProvisionerHint:  c.String("engine-provisioner-hint"),
+		MountFlags:       &mountFlags,
+		DaemonBinary:     c.String("engine-daemon-binary"),
+	}
+}
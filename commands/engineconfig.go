@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/provision"
+)
+
+var errNoProvisioner = errors.New("Error: No provisioner specified. Use the --provisioner flag.")
+
+// previewDriver stands in for the driver of a machine which is never
+// created, reporting the name of the driver the options are previewed for.
+type previewDriver struct {
+	*none.Driver
+	name string
+}
+
+func (d *previewDriver) DriverName() string {
+	return d.name
+}
+
+func engineConfigFlags() []cli.Flag {
+	flags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "provisioner",
+			Usage: "Provisioner to generate the engine config with, e.g. RedHat or Ubuntu-SystemD",
+		},
+		cli.StringFlag{
+			Name:  "driver, d",
+			Usage: "Driver the machine would be created with",
+			Value: "none",
+		},
+	}
+
+	for _, flag := range SharedCreateFlags {
+		if strings.HasPrefix(flag.String(), "--engine-") {
+			flags = append(flags, flag)
+		}
+	}
+
+	return flags
+}
+
+func cmdEngineConfig(c CommandLine, api libmachine.API) error {
+	return printEngineConfig(c, os.Stdout)
+}
+
+func printEngineConfig(c CommandLine, out io.Writer) error {
+	provisionerName := c.String("provisioner")
+	if provisionerName == "" {
+		return errNoProvisioner
+	}
+
+	d := &previewDriver{
+		Driver: none.NewDriver("", ""),
+		name:   c.String("driver"),
+	}
+
+	dockerOptions, err := provision.PreviewDockerOptions(provisionerName, d, *engineOptionsFromFlags(c))
+	if err != nil {
+		return err
+	}
+
+	printDockerOptions(dockerOptions, out)
+	return nil
+}
+
+// printDockerOptions prints each file of the daemon options written to a host,
+// headed by its path.
+func printDockerOptions(dockerOptions *provision.DockerOptions, out io.Writer) {
+	// the files written to the host, in the order they are written
+	files := []struct {
+		path, content string
+	}{
+		{dockerOptions.EngineOptionsPath, dockerOptions.EngineOptions},
+		{dockerOptions.SocketOptionsPath, dockerOptions.SocketOptions},
+		{dockerOptions.DaemonJSONPath, dockerOptions.DaemonJSON},
+		{dockerOptions.EnvFilePath, dockerOptions.EnvFile},
+	}
+
+	for _, file := range files {
+		if file.content != "" {
+			fmt.Fprintf(out, "# %s\n%s\n", file.path, file.content)
+		}
+	}
+}
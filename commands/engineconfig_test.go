@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintEngineConfig(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"provisioner":           "RedHat",
+				"driver":                "virtualbox",
+				"engine-storage-driver": "overlay",
+				"engine-mount-flags":    "slave",
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	err := printEngineConfig(commandLine, &out)
+
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "# /etc/systemd/system/docker.service\n[Unit]\n")
+	assert.Contains(t, out.String(), "--storage-driver overlay")
+	assert.Contains(t, out.String(), "--label provider=virtualbox")
+	assert.Contains(t, out.String(), "MountFlags=slave\n")
+}
+
+func TestPrintEngineConfigDefaultStorageDriver(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"provisioner": "RedHat",
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	err := printEngineConfig(commandLine, &out)
+
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "\nExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock --tlsverify ")
+	assert.NotContains(t, out.String(), "--storage-driver")
+}
+
+func TestPrintDockerOptions(t *testing.T) {
+	var out bytes.Buffer
+	printDockerOptions(&provision.DockerOptions{
+		EngineOptions:     "[Service]\nExecStart=/usr/bin/dockerd\n",
+		EngineOptionsPath: "/etc/systemd/system/docker.service",
+		DaemonJSON:        `{"hosts":["tcp://0.0.0.0:2376"]}`,
+		DaemonJSONPath:    "/etc/docker/daemon.json",
+		EnvFile:           "HTTP_PROXY=http://proxy:3128\n",
+		EnvFilePath:       "/etc/docker/docker.env",
+	}, &out)
+
+	assert.Equal(t, "# /etc/systemd/system/docker.service\n[Service]\nExecStart=/usr/bin/dockerd\n\n"+
+		"# /etc/docker/daemon.json\n{\"hosts\":[\"tcp://0.0.0.0:2376\"]}\n"+
+		"# /etc/docker/docker.env\nHTTP_PROXY=http://proxy:3128\n\n", out.String())
+}
+
+func TestPrintEngineConfigWithoutProvisioner(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{},
+		},
+	}
+
+	err := printEngineConfig(commandLine, &bytes.Buffer{})
+
+	assert.Equal(t, errNoProvisioner, err)
+}
@@ -12,6 +12,13 @@ type Options struct {
 	ServerKeyRemotePath  string
 	ClientCertPath       string
 	ServerCertSANs       []string
+	// KeyAlgorithm is "rsa" (the default) or "ecdsa". KeyBits is the RSA key
+	// size or ECDSA curve size; 0 picks the default for the algorithm.
+	KeyAlgorithm string
+	KeyBits      int
+	// RemoteCertDir is where the CA and server certs are copied to on the
+	// remote host; it defaults to the provisioner's Docker options directory.
+	RemoteCertDir string
 	// StorePath is left in for historical reasons, but not really meant to
 	// be used directly.
 	StorePath string
@@ -23,7 +23,8 @@ func BootstrapCertificates(authOptions *auth.Options) error {
 	caOrg := mcnutils.GetUsername()
 	org := caOrg + ".<bootstrap>"
 
-	bits := 2048
+	algorithm := authOptions.KeyAlgorithm
+	bits := authOptions.KeyBits
 
 	if _, err := os.Stat(certDir); err != nil {
 		if os.IsNotExist(err) {
@@ -43,7 +44,7 @@ func BootstrapCertificates(authOptions *auth.Options) error {
 			return errors.New("The CA key already exists.  Please remove it or specify a different key/cert.")
 		}
 
-		if err := GenerateCACertificate(caCertPath, caPrivateKeyPath, caOrg, bits); err != nil {
+		if err := GenerateCACertificateWithKey(caCertPath, caPrivateKeyPath, caOrg, algorithm, bits); err != nil {
 			return fmt.Errorf("Generating CA certificate failed: %s", err)
 		}
 	}
@@ -66,7 +67,7 @@ func BootstrapCertificates(authOptions *auth.Options) error {
 			return errors.New("The client key already exists.  Please remove it or specify a different key/cert.")
 		}
 
-		if err := GenerateCert([]string{""}, clientCertPath, clientKeyPath, caCertPath, caPrivateKeyPath, org, bits); err != nil {
+		if err := GenerateCertWithKey([]string{""}, clientCertPath, clientKeyPath, caCertPath, caPrivateKeyPath, org, algorithm, bits); err != nil {
 			return fmt.Errorf("Generating client certificate failed: %s", err)
 		}
 	}
@@ -1,12 +1,17 @@
 package cert
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
 	"net"
@@ -19,6 +24,14 @@ import (
 	"github.com/docker/machine/libmachine/log"
 )
 
+const (
+	KeyAlgorithmRSA   = "rsa"
+	KeyAlgorithmECDSA = "ecdsa"
+
+	// DefaultKeyBits is the RSA key size used when none is specified.
+	DefaultKeyBits = 2048
+)
+
 var defaultGenerator = NewX509CertGenerator()
 
 type Generator interface {
@@ -28,6 +41,13 @@ type Generator interface {
 	ValidateCertificate(addr string, authOptions *auth.Options) (bool, error)
 }
 
+// KeyGenerator is implemented by Generators able to create private keys of
+// an algorithm other than RSA.
+type KeyGenerator interface {
+	GenerateCACertificateWithKey(certFile, keyFile, org, algorithm string, bits int) error
+	GenerateCertWithKey(hosts []string, certFile, keyFile, caFile, caKeyFile, org, algorithm string, bits int) error
+}
+
 type X509CertGenerator struct{}
 
 func NewX509CertGenerator() Generator {
@@ -42,6 +62,31 @@ func GenerateCert(hosts []string, certFile, keyFile, caFile, caKeyFile, org stri
 	return defaultGenerator.GenerateCert(hosts, certFile, keyFile, caFile, caKeyFile, org, bits)
 }
 
+// GenerateCACertificateWithKey is GenerateCACertificate with the private key
+// algorithm ("rsa" or "ecdsa") chosen by the caller. For ECDSA keys bits
+// selects the curve size.
+func GenerateCACertificateWithKey(certFile, keyFile, org, algorithm string, bits int) error {
+	if kg, ok := defaultGenerator.(KeyGenerator); ok {
+		return kg.GenerateCACertificateWithKey(certFile, keyFile, org, algorithm, bits)
+	}
+	if !isRSA(algorithm) {
+		return fmt.Errorf("certificate generator does not support %s keys", algorithm)
+	}
+	return defaultGenerator.GenerateCACertificate(certFile, keyFile, org, rsaKeyBits(bits))
+}
+
+// GenerateCertWithKey is GenerateCert with the private key algorithm ("rsa"
+// or "ecdsa") chosen by the caller. For ECDSA keys bits selects the curve size.
+func GenerateCertWithKey(hosts []string, certFile, keyFile, caFile, caKeyFile, org, algorithm string, bits int) error {
+	if kg, ok := defaultGenerator.(KeyGenerator); ok {
+		return kg.GenerateCertWithKey(hosts, certFile, keyFile, caFile, caKeyFile, org, algorithm, bits)
+	}
+	if !isRSA(algorithm) {
+		return fmt.Errorf("certificate generator does not support %s keys", algorithm)
+	}
+	return defaultGenerator.GenerateCert(hosts, certFile, keyFile, caFile, caKeyFile, org, rsaKeyBits(bits))
+}
+
 func ValidateCertificate(addr string, authOptions *auth.Options) (bool, error) {
 	return defaultGenerator.ValidateCertificate(addr, authOptions)
 }
@@ -54,6 +99,65 @@ func SetCertGenerator(cg Generator) {
 	defaultGenerator = cg
 }
 
+func isRSA(algorithm string) bool {
+	return algorithm == "" || algorithm == KeyAlgorithmRSA
+}
+
+func rsaKeyBits(bits int) int {
+	if bits == 0 {
+		return DefaultKeyBits
+	}
+	return bits
+}
+
+// generatePrivateKey creates a private key of the given algorithm. bits is the
+// RSA key size, or the ECDSA curve size (256, 384 or 521), 0 meaning the
+// default of each.
+func generatePrivateKey(algorithm string, bits int) (crypto.Signer, error) {
+	switch algorithm {
+	case "", KeyAlgorithmRSA:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits(bits))
+	case KeyAlgorithmECDSA:
+		switch bits {
+		case 0, 256:
+			return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		case 384:
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		case 521:
+			return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA key size %d, expected 256, 384 or 521", bits)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q, expected %q or %q", algorithm, KeyAlgorithmRSA, KeyAlgorithmECDSA)
+	}
+}
+
+// keyEnciphermentUsage returns the key encipherment usage for RSA keys, which
+// the TLS key exchange encrypts with, and no usage for ECDSA keys which
+// cannot encrypt.
+func keyEnciphermentUsage(priv crypto.Signer) x509.KeyUsage {
+	if _, ok := priv.(*rsa.PrivateKey); ok {
+		return x509.KeyUsageKeyEncipherment
+	}
+	return 0
+}
+
+func encodePrivateKey(out io.Writer, priv crypto.Signer) error {
+	switch key := priv.(type) {
+	case *rsa.PrivateKey:
+		return pem.Encode(out, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		return pem.Encode(out, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	default:
+		return fmt.Errorf("unsupported private key type %T", priv)
+	}
+}
+
 func (xcg *X509CertGenerator) getTLSConfig(caCert, cert, key []byte, allowInsecure bool) (*tls.Config, error) {
 	// TLS config
 	var tlsConfig tls.Config
@@ -96,7 +200,7 @@ func (xcg *X509CertGenerator) newCertificate(org string) (*x509.Certificate, err
 		NotBefore: notBefore,
 		NotAfter:  notAfter,
 
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
 		BasicConstraintsValid: true,
 	}, nil
 
@@ -106,6 +210,12 @@ func (xcg *X509CertGenerator) newCertificate(org string) (*x509.Certificate, err
 // and bit size and stores the resulting certificate and key file
 // in the arguments.
 func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org string, bits int) error {
+	return xcg.GenerateCACertificateWithKey(certFile, keyFile, org, KeyAlgorithmRSA, bits)
+}
+
+// GenerateCACertificateWithKey generates a new certificate authority like
+// GenerateCACertificate, using a private key of the given algorithm.
+func (xcg *X509CertGenerator) GenerateCACertificateWithKey(certFile, keyFile, org, algorithm string, bits int) error {
 	template, err := xcg.newCertificate(org)
 	if err != nil {
 		return err
@@ -113,15 +223,15 @@ func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org strin
 
 	template.IsCA = true
 	template.KeyUsage |= x509.KeyUsageCertSign
-	template.KeyUsage |= x509.KeyUsageKeyEncipherment
 	template.KeyUsage |= x509.KeyUsageKeyAgreement
 
-	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	priv, err := generatePrivateKey(algorithm, bits)
 	if err != nil {
 		return err
 	}
+	template.KeyUsage |= keyEnciphermentUsage(priv)
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
 	if err != nil {
 		return err
 	}
@@ -139,11 +249,9 @@ func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org strin
 		return err
 
 	}
+	defer keyOut.Close()
 
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	keyOut.Close()
-
-	return nil
+	return encodePrivateKey(keyOut, priv)
 }
 
 // GenerateCert generates a new certificate signed using the provided
@@ -151,16 +259,28 @@ func (xcg *X509CertGenerator) GenerateCACertificate(certFile, keyFile, org strin
 // file and key provided.  The provided host names are set to the
 // appropriate certificate fields.
 func (xcg *X509CertGenerator) GenerateCert(hosts []string, certFile, keyFile, caFile, caKeyFile, org string, bits int) error {
+	return xcg.GenerateCertWithKey(hosts, certFile, keyFile, caFile, caKeyFile, org, KeyAlgorithmRSA, bits)
+}
+
+// GenerateCertWithKey generates a new certificate like GenerateCert, using a
+// private key of the given algorithm.
+func (xcg *X509CertGenerator) GenerateCertWithKey(hosts []string, certFile, keyFile, caFile, caKeyFile, org, algorithm string, bits int) error {
 	template, err := xcg.newCertificate(org)
 	if err != nil {
 		return err
 	}
+	priv, err := generatePrivateKey(algorithm, bits)
+	if err != nil {
+		return err
+	}
+
 	// client
 	if len(hosts) == 1 && hosts[0] == "" {
 		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
 		template.KeyUsage = x509.KeyUsageDigitalSignature
 	} else { // server
 		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
+		template.KeyUsage |= keyEnciphermentUsage(priv)
 		for _, h := range hosts {
 			if ip := net.ParseIP(h); ip != nil {
 				template.IPAddresses = append(template.IPAddresses, ip)
@@ -175,17 +295,12 @@ func (xcg *X509CertGenerator) GenerateCert(hosts []string, certFile, keyFile, ca
 		return err
 	}
 
-	priv, err := rsa.GenerateKey(rand.Reader, bits)
-	if err != nil {
-		return err
-	}
-
 	x509Cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
 	if err != nil {
 		return err
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, template, x509Cert, &priv.PublicKey, tlsCert.PrivateKey)
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, x509Cert, priv.Public(), tlsCert.PrivateKey)
 	if err != nil {
 		return err
 	}
@@ -202,11 +317,9 @@ func (xcg *X509CertGenerator) GenerateCert(hosts []string, certFile, keyFile, ca
 	if err != nil {
 		return err
 	}
+	defer keyOut.Close()
 
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	keyOut.Close()
-
-	return nil
+	return encodePrivateKey(keyOut, priv)
 }
 
 // ReadTLSConfig reads the tls config for a machine.
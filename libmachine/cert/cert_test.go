@@ -1,6 +1,10 @@
 package cert
 
 import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -68,3 +72,67 @@ func TestGenerateCert(t *testing.T) {
 		t.Fatalf("key not created at %s", keyPath)
 	}
 }
+
+func TestGenerateCertWithECDSAKey(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "key.pem")
+	certPath := filepath.Join(tmpDir, "cert.pem")
+	keyPath := filepath.Join(tmpDir, "cert-key.pem")
+	testOrg := "test-org"
+	if err := GenerateCACertificateWithKey(caCertPath, caKeyPath, testOrg, KeyAlgorithmECDSA, 384); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GenerateCertWithKey([]string{}, certPath, keyPath, caCertPath, caKeyPath, testOrg, KeyAlgorithmECDSA, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("no PEM data found in %s", certPath)
+	}
+	x509Cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := x509Cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected an ECDSA public key, got %T", x509Cert.PublicKey)
+	}
+	if pub.Curve.Params().BitSize != 256 {
+		t.Fatalf("expected a P-256 key, got %d bits", pub.Curve.Params().BitSize)
+	}
+	if x509Cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+		t.Fatal("expected no key encipherment usage on an ECDSA certificate")
+	}
+
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateCertWithUnknownKeyAlgorithm(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cleanup
+	defer os.RemoveAll(tmpDir)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "key.pem")
+	if err := GenerateCACertificateWithKey(caCertPath, caKeyPath, "test-org", "dsa", 0); err == nil {
+		t.Fatal("expected an error for an unsupported key algorithm")
+	}
+}
@@ -5,15 +5,20 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
 )
 
 var (
 	DefaultConnChecker ConnChecker
 	ErrSwarmNotStarted = errors.New("Connection to Swarm cannot be checked but the certs are valid. Maybe swarm is not started")
+
+	// ConnCheckInterval is the delay between two checks done by WaitForConn.
+	ConnCheckInterval = 3 * time.Second
 )
 
 func init() {
@@ -33,6 +38,18 @@ Be advised that this will trigger a Docker daemon restart which will stop runnin
 `, e.hostURL, e.wrappedErr)
 }
 
+// ErrDaemonUnreachable for when the Docker daemon of a host never answered
+// within the time it was given.
+type ErrDaemonUnreachable struct {
+	wrappedErr error
+	hostName   string
+	timeout    time.Duration
+}
+
+func (e ErrDaemonUnreachable) Error() string {
+	return fmt.Sprintf("The Docker daemon on host %q did not respond within %s: %s", e.hostName, e.timeout, e.wrappedErr)
+}
+
 type ConnChecker interface {
 	Check(*host.Host, bool) (dockerHost string, authOptions *auth.Options, err error)
 }
@@ -76,6 +93,30 @@ func (mcc *MachineConnChecker) Check(h *host.Host, swarm bool) (string, *auth.Op
 	return dockerURL, authOptions, nil
 }
 
+// WaitForConn checks the connection to the Docker daemon of the host until it
+// succeeds or the timeout expires. A zero timeout checks only once.
+func WaitForConn(checker ConnChecker, h *host.Host, swarm bool, timeout time.Duration) (string, *auth.Options, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		dockerHost, authOptions, err := checker.Check(h, swarm)
+		if err == nil {
+			return dockerHost, authOptions, nil
+		}
+
+		if time.Now().Add(ConnCheckInterval).After(deadline) {
+			return "", &auth.Options{}, ErrDaemonUnreachable{
+				wrappedErr: err,
+				hostName:   h.Name,
+				timeout:    timeout,
+			}
+		}
+
+		log.Debugf("Docker daemon not responding yet: %s", err)
+		time.Sleep(ConnCheckInterval)
+	}
+}
+
 func checkCert(hostURL string, authOptions *auth.Options) error {
 	valid, err := cert.ValidateCertificate(hostURL, authOptions)
 	if !valid || err != nil {
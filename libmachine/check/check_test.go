@@ -3,11 +3,13 @@ package check
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"crypto/tls"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,3 +60,43 @@ func TestCheckCert(t *testing.T) {
 		assert.Equal(t, c.expectedErr, err)
 	}
 }
+
+type FakeConnChecker struct {
+	Calls    int
+	Failures int
+	Err      error
+}
+
+func (fcc *FakeConnChecker) Check(h *host.Host, swarm bool) (string, *auth.Options, error) {
+	fcc.Calls++
+	if fcc.Failures < 0 || fcc.Calls <= fcc.Failures {
+		return "", &auth.Options{}, fcc.Err
+	}
+	return "tcp://192.168.99.100:2376", &auth.Options{}, nil
+}
+
+func TestWaitForConnTimeout(t *testing.T) {
+	defer func(interval time.Duration) { ConnCheckInterval = interval }(ConnCheckInterval)
+	ConnCheckInterval = time.Millisecond
+
+	errRefused := errors.New("connection refused")
+	fcc := &FakeConnChecker{Failures: -1, Err: errRefused}
+
+	_, _, err := WaitForConn(fcc, &host.Host{Name: "default"}, false, 20*time.Millisecond)
+
+	assert.Equal(t, ErrDaemonUnreachable{wrappedErr: errRefused, hostName: "default", timeout: 20 * time.Millisecond}, err)
+	assert.True(t, fcc.Calls > 1)
+}
+
+func TestWaitForConnSucceedsAfterRetries(t *testing.T) {
+	defer func(interval time.Duration) { ConnCheckInterval = interval }(ConnCheckInterval)
+	ConnCheckInterval = time.Millisecond
+
+	fcc := &FakeConnChecker{Failures: 2, Err: errors.New("connection refused")}
+
+	dockerHost, _, err := WaitForConn(fcc, &host.Host{Name: "default"}, false, time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://192.168.99.100:2376", dockerHost)
+	assert.Equal(t, 3, fcc.Calls)
+}
@@ -2,6 +2,7 @@ package drivers
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -34,6 +35,25 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 }
 
 func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
+	return runSSHCommandFromDriver(d, command, func(client ssh.Client) (string, error) {
+		return client.Output(command)
+	})
+}
+
+// RunSSHCommandWithStdinFromDriver runs command like RunSSHCommandFromDriver,
+// with stdin as its standard input. What is read from stdin is neither logged
+// nor part of the returned error.
+func RunSSHCommandWithStdinFromDriver(d Driver, command string, stdin io.Reader) (string, error) {
+	return runSSHCommandFromDriver(d, command, func(client ssh.Client) (string, error) {
+		stdinClient, ok := client.(ssh.StdinClient)
+		if !ok {
+			return "", fmt.Errorf("the %T SSH client cannot feed the standard input of a command", client)
+		}
+		return stdinClient.OutputWithStdin(command, stdin)
+	})
+}
+
+func runSSHCommandFromDriver(d Driver, command string, run func(client ssh.Client) (string, error)) (string, error) {
 	client, err := GetSSHClientFromDriver(d)
 	if err != nil {
 		return "", err
@@ -41,7 +61,7 @@ func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
 
 	log.Debugf("About to run SSH command:\n%s", command)
 
-	output, err := client.Output(command)
+	output, err := run(client)
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
 		return "", fmt.Errorf(`Something went wrong running an SSH command!
@@ -1,9 +1,21 @@
 package engine
 
 const (
-	DefaultPort = 2376
+	DefaultPort          = 2376
+	DefaultListenAddress = "0.0.0.0"
+	DefaultMountFlags    = "slave"
+	DefaultDaemonBinary  = "/usr/bin/docker"
+	DefaultDockerdBinary = "/usr/bin/dockerd"
+	DefaultWantedBy      = "multi-user.target"
+
+	DefaultUnitDescription   = "Docker Application Container Engine"
+	DefaultUnitDocumentation = "https://docs.docker.com"
 )
 
+// DefaultNoProxy are the internal ranges added to the NO_PROXY of a daemon
+// configured with a proxy.
+var DefaultNoProxy = []string{"localhost", "127.0.0.1", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
 type Options struct {
 	ArbitraryFlags   []string
 	DNS              []string `json:"Dns"`
@@ -14,8 +26,124 @@ type Options struct {
 	Labels           []string
 	LogLevel         string
 	StorageDriver    string
+	StorageOpts      []string
 	SelinuxEnabled   bool
 	TLSVerify        bool `json:"TlsVerify"`
+	// DisableTLSVerify starts the daemon listening on TCP without
+	// --tlsverify and the server certs, for debugging. TLS is verified when
+	// it is false, whatever TLSVerify is set to.
+	DisableTLSVerify bool
 	RegistryMirror   []string
 	InstallURL       string
+	ListenAddress    string
+	ProvisionerHint  string
+	// Version pins the Docker version installed from InstallURL, e.g.
+	// "17.03", which is passed to the install script as VERSION. The latest
+	// one is installed when empty.
+	Version string
+	// ExtraHosts are additional -H endpoints the daemon listens on, after
+	// the TCP and unix sockets it always binds.
+	ExtraHosts []string
+	// SkipSelinuxDetection keeps SelinuxEnabled from being turned on when
+	// SELinux is found enforcing on a RedHat family host.
+	SkipSelinuxDetection bool
+	// SkipHostname leaves the hostname of the host as it is instead of
+	// setting it to the machine name, for hosts whose hostname is managed by
+	// cloud-init or DNS.
+	SkipHostname bool
+	// MountFlags is the MountFlags directive of the systemd unit. It is
+	// DefaultMountFlags when nil, and left out of the unit when empty.
+	MountFlags *string
+	// DaemonBinary is the absolute path of the binary started by the
+	// systemd unit, optionally followed by the subcommand to run it with.
+	DaemonBinary string
+	// SystemdWantedBy is the target the systemd unit is installed in,
+	// DefaultWantedBy being used when empty.
+	SystemdWantedBy string
+	// ExtraCACerts are the paths of local PEM files added to the trusted CAs
+	// of the host.
+	ExtraCACerts []string
+	// PreloadImages are pulled on the host once the daemon is started.
+	PreloadImages []string
+	// ConfigURL serves JSON encoded engine options, which are used for the
+	// options left empty.
+	ConfigURL string
+	// NoProxy lists the entries added along with the machine's IP to the
+	// NO_PROXY of a daemon configured with a proxy. DefaultNoProxy is used
+	// when nil.
+	NoProxy []string
+	// UseEnvFile moves the environment of the daemon from its systemd unit
+	// to an EnvironmentFile.
+	UseEnvFile bool
+	// UseDaemonJSON moves the daemon settings from the command line of the
+	// systemd unit to a daemon.json, the unit then running dockerd.
+	UseDaemonJSON bool
+	// LimitCore is the LimitCORE of the systemd unit, "0" disabling core
+	// dumps. It defaults to "infinity" and is overridden by Ulimits["core"].
+	LimitCore string
+	// Ulimits overrides the nofile, nproc and core limits of the systemd
+	// unit with a number or "infinity".
+	Ulimits map[string]string
+	// UnitDescription and UnitDocumentation brand the Description and
+	// Documentation of the systemd unit, DefaultUnitDescription and
+	// DefaultUnitDocumentation being used when empty.
+	UnitDescription   string
+	UnitDocumentation string
+	// UnitAfter and UnitRequires are the units the daemon's systemd unit is
+	// ordered after and requires, e.g. remote-fs.target or the mount unit of
+	// /var/lib/docker. UnitAfter replaces the default ordering when set.
+	UnitAfter    []string
+	UnitRequires []string
+	// SystemdServiceOverrides maps [Service] directives of the daemon's
+	// systemd unit, e.g. TasksMax, to their value. The directives the
+	// provisioner already sets cannot be overridden, except Environment:
+	// its variables take precedence over those of Env, but not over those
+	// of the EnvironmentFile written with UseEnvFile.
+	SystemdServiceOverrides map[string]string
+	// AutoLabels adds a machine=<name> label to the daemon unless a machine
+	// label is already configured.
+	AutoLabels bool
+	// Runtimes maps the name of an OCI runtime, e.g. nvidia, to the absolute
+	// path of its binary on the host.
+	Runtimes map[string]string
+	// DefaultRuntime is the runtime containers are run with, runc or one of
+	// Runtimes. The daemon's default is used when empty.
+	DefaultRuntime string
+	// LogDriver is the default logging driver of the containers, e.g.
+	// journald, configured with LogOpts. The daemon's defaults are used when
+	// empty.
+	LogDriver string
+	LogOpts   map[string]string
+	// CgroupDriver is the cgroup driver of the daemon, systemd or cgroupfs,
+	// which must match the kubelet's on Kubernetes nodes. The daemon's
+	// default is used when empty.
+	CgroupDriver string
+	// SocketGroup is the group owning the unix socket of the daemon, whose
+	// members may use Docker without root. It defaults to docker.
+	SocketGroup string
+	// LiveRestore keeps the containers running while the daemon restarts,
+	// e.g. when its certs are rotated. It cannot be used with swarm-mode.
+	LiveRestore bool
+	// UsernsRemap maps the root of the containers to an unprivileged user of
+	// the host: "default", or a "user[:group]" with subordinate ids.
+	UsernsRemap string
+	// DataRoot is where the daemon keeps its images and containers, e.g. on a
+	// dedicated volume. The daemon's default is used when empty.
+	DataRoot string
+	// BridgeIP is the address and netmask of the docker0 bridge, e.g.
+	// "192.168.200.1/24", for hosts whose networks collide with its default.
+	BridgeIP string
+	// DefaultAddressPools are the pools the networks of the daemon get their
+	// subnets from, each as "base=10.10.0.0/16,size=24" like the daemon's
+	// --default-address-pool.
+	DefaultAddressPools []string
+	// DefaultUlimits maps a ulimit, e.g. nofile, to the "soft:hard" limits
+	// the containers get by default.
+	DefaultUlimits map[string]string
+	// MinDockerVersion is the oldest Docker version an upgrade of the host
+	// may leave it with, e.g. "1.12.0". Any version is accepted when empty.
+	MinDockerVersion string
+	// RegistryMirrorAuth maps a registry mirror to the "user:password"
+	// credentials used to pull from it.
+	RegistryMirrorAuth map[string]string
 }
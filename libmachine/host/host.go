@@ -106,8 +106,17 @@ func (h *Host) runActionForState(action func() error, desiredState state.State)
 	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
 }
 
+func (h *Host) detectProvisioner() (provision.Provisioner, error) {
+	hint := ""
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		hint = h.HostOptions.EngineOptions.ProvisionerHint
+	}
+
+	return provision.DetectProvisionerWithHint(h.Driver, hint)
+}
+
 func (h *Host) WaitForDocker() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.detectProvisioner()
 	if err != nil {
 		return err
 	}
@@ -174,18 +183,32 @@ func (h *Host) Upgrade() error {
 		return errMachineMustBeRunningForUpgrade
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.detectProvisioner()
 	if err != nil {
 		return err
 	}
 
+	previousVersion, err := provisioner.GetDockerVersion()
+	if err != nil {
+		log.Debugf("Unable to get the Docker version before the upgrade: %s", err)
+	}
+
 	log.Info("Upgrading docker...")
 	if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
 		return err
 	}
 
 	log.Info("Restarting docker...")
-	return provisioner.Service("docker", serviceaction.Restart)
+	if err := provisioner.Service("docker", serviceaction.Restart); err != nil {
+		return err
+	}
+
+	minVersion := ""
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		minVersion = h.HostOptions.EngineOptions.MinDockerVersion
+	}
+
+	return provision.CheckDockerUpgrade(provisioner, previousVersion, minVersion)
 }
 
 func (h *Host) URL() (string, error) {
@@ -200,7 +223,7 @@ func (h *Host) AuthOptions() *auth.Options {
 }
 
 func (h *Host) ConfigureAuth() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.detectProvisioner()
 	if err != nil {
 		return err
 	}
@@ -210,14 +233,56 @@ func (h *Host) ConfigureAuth() error {
 	// and modularity of the provisioners should be).
 	//
 	// Call provision to re-provision the certs properly.
-	return provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err := provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+		return err
+	}
+
+	h.UpdateResolvedEngineOptions(provisioner)
+	return nil
 }
 
 func (h *Host) Provision() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.detectProvisioner()
 	if err != nil {
 		return err
 	}
 
-	return provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+		return err
+	}
+
+	h.UpdateResolvedEngineOptions(provisioner)
+	h.UpdateResolvedSwarmOptions(provisioner)
+	return nil
+}
+
+// UpdateResolvedEngineOptions records the engine options the provisioner
+// picked defaults for, such as the storage driver, in the host options so
+// that they are persisted and the same ones are used when provisioning again.
+func (h *Host) UpdateResolvedEngineOptions(provisioner provision.Provisioner) {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return
+	}
+
+	if storageDriver := provisioner.GetEngineOptions().StorageDriver; storageDriver != "" {
+		h.HostOptions.EngineOptions.StorageDriver = storageDriver
+	}
+}
+
+// UpdateResolvedSwarmOptions records the unlock key of the swarm-mode cluster
+// the provisioner locked in the host options, so that it is persisted and the
+// managers can be unlocked once restarted.
+func (h *Host) UpdateResolvedSwarmOptions(provisioner provision.Provisioner) {
+	if h.HostOptions == nil || h.HostOptions.SwarmOptions == nil {
+		return
+	}
+
+	swarmOptionser, ok := provisioner.(provision.SwarmOptionser)
+	if !ok {
+		return
+	}
+
+	if unlockKey := swarmOptionser.GetSwarmOptions().UnlockKey; unlockKey != "" {
+		h.HostOptions.SwarmOptions.UnlockKey = unlockKey
+	}
 }
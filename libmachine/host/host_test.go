@@ -5,8 +5,11 @@ import (
 
 	"github.com/docker/machine/drivers/fakedriver"
 	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
 )
 
 func TestValidateHostnameValid(t *testing.T) {
@@ -55,3 +58,83 @@ func TestStart(t *testing.T) {
 		t.Fatalf("Expected no error but got one: %s", err)
 	}
 }
+
+type storageDriverProvisioner struct {
+	*provision.FakeProvisioner
+	engineOptions engine.Options
+}
+
+func (p *storageDriverProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	p.engineOptions = engineOptions
+	if p.engineOptions.StorageDriver == "" {
+		p.engineOptions.StorageDriver = "overlay"
+	}
+	return nil
+}
+
+func (p *storageDriverProvisioner) GetEngineOptions() engine.Options {
+	return p.engineOptions
+}
+
+func TestProvisionKeepsResolvedStorageDriver(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: &storageDriverProvisioner{FakeProvisioner: &provision.FakeProvisioner{}},
+	})
+
+	host := &Host{
+		Driver: &fakedriver.Driver{MockState: state.Running},
+		HostOptions: &Options{
+			EngineOptions: &engine.Options{},
+			SwarmOptions:  &swarm.Options{},
+			AuthOptions:   &auth.Options{},
+		},
+	}
+
+	if err := host.Provision(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	if host.HostOptions.EngineOptions.StorageDriver != "overlay" {
+		t.Fatalf("Expected the resolved storage driver to be kept, got %q", host.HostOptions.EngineOptions.StorageDriver)
+	}
+}
+
+type unlockKeyProvisioner struct {
+	*provision.FakeProvisioner
+	swarmOptions swarm.Options
+}
+
+func (p *unlockKeyProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	p.swarmOptions = swarmOptions
+	p.swarmOptions.UnlockKey = "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8"
+	return nil
+}
+
+func (p *unlockKeyProvisioner) GetSwarmOptions() swarm.Options {
+	return p.swarmOptions
+}
+
+func TestProvisionKeepsSwarmUnlockKey(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: &unlockKeyProvisioner{FakeProvisioner: &provision.FakeProvisioner{}},
+	})
+
+	host := &Host{
+		Driver: &fakedriver.Driver{MockState: state.Running},
+		HostOptions: &Options{
+			EngineOptions: &engine.Options{},
+			SwarmOptions:  &swarm.Options{IsSwarm: true, Master: true, Mode: swarm.ModeSwarm, Autolock: true},
+			AuthOptions:   &auth.Options{},
+		},
+	}
+
+	if err := host.Provision(); err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+
+	if host.HostOptions.SwarmOptions.UnlockKey != "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8" {
+		t.Fatalf("Expected the unlock key to be kept, got %q", host.HostOptions.SwarmOptions.UnlockKey)
+	}
+}
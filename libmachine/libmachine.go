@@ -3,6 +3,7 @@ package libmachine
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"io"
 
@@ -34,11 +35,16 @@ type API interface {
 	GetMachinesDir() string
 }
 
+// DefaultDockerCheckTimeout is how long Create waits for the Docker daemon of
+// a provisioned machine to respond.
+const DefaultDockerCheckTimeout = 2 * time.Minute
+
 type Client struct {
-	certsDir       string
-	IsDebug        bool
-	SSHClientType  ssh.ClientType
-	GithubAPIToken string
+	certsDir           string
+	IsDebug            bool
+	SSHClientType      ssh.ClientType
+	GithubAPIToken     string
+	DockerCheckTimeout time.Duration
 	*persist.Filestore
 	clientDriverFactory rpcdriver.RPCClientDriverFactory
 }
@@ -48,6 +54,7 @@ func NewClient(storePath, certsDir string) *Client {
 		certsDir:            certsDir,
 		IsDebug:             false,
 		SSHClientType:       ssh.External,
+		DockerCheckTimeout:  DefaultDockerCheckTimeout,
 		Filestore:           persist.NewFilestore(storePath, certsDir, certsDir),
 		clientDriverFactory: rpcdriver.NewRPCClientDriverFactory(),
 	}
@@ -163,7 +170,10 @@ func (api *Client) performCreate(h *host.Host) error {
 	}
 
 	log.Info("Detecting operating system of created instance...")
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := provision.DetectProvisionerWithHint(h.Driver, h.HostOptions.EngineOptions.ProvisionerHint)
+	if _, ok := err.(provision.ErrOsReleaseParse); ok {
+		return fmt.Errorf("Error detecting OS: %s. Pass --engine-provisioner-hint to provision the host with a generic systemd provisioner", err)
+	}
 	if err != nil {
 		return fmt.Errorf("Error detecting OS: %s", err)
 	}
@@ -173,9 +183,12 @@ func (api *Client) performCreate(h *host.Host) error {
 		return fmt.Errorf("Error running provisioning: %s", err)
 	}
 
+	h.UpdateResolvedEngineOptions(provisioner)
+	h.UpdateResolvedSwarmOptions(provisioner)
+
 	// We should check the connection to docker here
 	log.Info("Checking connection to Docker...")
-	if _, _, err = check.DefaultConnChecker.Check(h, false); err != nil {
+	if _, _, err = check.WaitForConn(check.DefaultConnChecker, h, false, api.DockerCheckTimeout); err != nil {
 		return fmt.Errorf("Error checking the host: %s", err)
 	}
 
@@ -0,0 +1,96 @@
+package libmachine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+type unlockKeyProvisioner struct {
+	*provision.FakeProvisioner
+	swarmOptions swarm.Options
+}
+
+func (p *unlockKeyProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	p.swarmOptions = swarmOptions
+	p.swarmOptions.UnlockKey = "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8"
+	return nil
+}
+
+func (p *unlockKeyProvisioner) GetSwarmOptions() swarm.Options {
+	return p.swarmOptions
+}
+
+type fakeConnChecker struct{}
+
+func (fcc *fakeConnChecker) Check(h *host.Host, swarm bool) (string, *auth.Options, error) {
+	return "tcp://1.2.3.4:2376", &auth.Options{}, nil
+}
+
+func TestCreateKeepsSwarmUnlockKey(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: &unlockKeyProvisioner{FakeProvisioner: &provision.FakeProvisioner{}},
+	})
+
+	defer func(checker check.ConnChecker) { check.DefaultConnChecker = checker }(check.DefaultConnChecker)
+	check.DefaultConnChecker = &fakeConnChecker{}
+
+	certsDir := filepath.Join(tmpDir, "certs")
+	api := &Client{
+		certsDir:           certsDir,
+		DockerCheckTimeout: DefaultDockerCheckTimeout,
+		Filestore:          persist.NewFilestore(tmpDir, certsDir, certsDir),
+	}
+
+	h := &host.Host{
+		ConfigVersion: version.ConfigVersion,
+		Name:          "test",
+		Driver: &fakedriver.Driver{
+			BaseDriver: &drivers.BaseDriver{MachineName: "test"},
+			MockState:  state.Running,
+		},
+		DriverName: "Driver",
+		HostOptions: &host.Options{
+			AuthOptions: &auth.Options{
+				CertDir:          certsDir,
+				CaCertPath:       filepath.Join(certsDir, "ca.pem"),
+				CaPrivateKeyPath: filepath.Join(certsDir, "ca-key.pem"),
+				ClientCertPath:   filepath.Join(certsDir, "cert.pem"),
+				ClientKeyPath:    filepath.Join(certsDir, "key.pem"),
+			},
+			EngineOptions: &engine.Options{},
+			SwarmOptions:  &swarm.Options{IsSwarm: true, Master: true, Mode: swarm.ModeSwarm, Autolock: true},
+		},
+	}
+
+	err = api.Create(h)
+	assert.NoError(t, err)
+
+	err = api.Save(h)
+	assert.NoError(t, err)
+
+	saved, err := api.Filestore.Load("test")
+	assert.NoError(t, err)
+	assert.Equal(t, "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8", saved.HostOptions.SwarmOptions.UnlockKey)
+}
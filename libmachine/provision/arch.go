@@ -33,6 +33,10 @@ func (provisioner *ArchProvisioner) String() string {
 	return "arch"
 }
 
+func (provisioner *ArchProvisioner) CATrustStore() (string, string) {
+	return "/etc/ca-certificates/trust-source/anchors", "sudo trust extract-compat"
+}
+
 func (provisioner *ArchProvisioner) CompatibleWithHost() bool {
 	return provisioner.OsReleaseInfo.ID == provisioner.OsReleaseID || provisioner.OsReleaseInfo.IDLike == provisioner.OsReleaseID
 }
@@ -89,6 +93,11 @@ func (provisioner *ArchProvisioner) dockerDaemonResponding() bool {
 }
 
 func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -106,8 +115,9 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOp
 		return err
 	}
 
-	log.Debug("Setting hostname")
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
@@ -135,13 +145,15 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOp
 
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	log.Debug("Configuring auth")
-	if err := ConfigureAuth(provisioner); err != nil {
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	log.Debug("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
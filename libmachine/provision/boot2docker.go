@@ -46,7 +46,7 @@ func (provisioner *Boot2DockerProvisioner) String() string {
 }
 
 func (provisioner *Boot2DockerProvisioner) Service(name string, action serviceaction.ServiceAction) error {
-	_, err := provisioner.SSHCommand(fmt.Sprintf("sudo /etc/init.d/%s %s", name, action.String()))
+	_, err := serviceCommand(provisioner, fmt.Sprintf("sudo /etc/init.d/%s %s", name, action.String()), action)
 	return err
 }
 
@@ -92,6 +92,9 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 		return err
 	}
 
+	// the host now runs the release of the new ISO
+	provisioner.SetOsReleaseInfo(nil)
+
 	return mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Running))
 }
 
@@ -128,13 +131,44 @@ func (provisioner *Boot2DockerProvisioner) GetAuthOptions() auth.Options {
 	return provisioner.AuthOptions
 }
 
+func (provisioner *Boot2DockerProvisioner) GetEngineOptions() engine.Options {
+	return provisioner.EngineOptions
+}
+
+func (provisioner *Boot2DockerProvisioner) GetSwarmOptions() swarm.Options {
+	return provisioner.SwarmOptions
+}
+
+func (provisioner *Boot2DockerProvisioner) SetSwarmUnlockKey(unlockKey string) {
+	provisioner.SwarmOptions.UnlockKey = unlockKey
+}
+
+func (provisioner *Boot2DockerProvisioner) GetDockerVersion() (string, error) {
+	return getDockerVersion(provisioner)
+}
+
+func (provisioner *Boot2DockerProvisioner) EnsureDockerConfig(dockerOptions DockerOptions) (bool, error) {
+	return ensureDockerConfig(provisioner, dockerOptions)
+}
+
+func (provisioner *Boot2DockerProvisioner) SetAuthOptions(authOptions auth.Options) {
+	provisioner.AuthOptions = authOptions
+}
+
+func (provisioner *Boot2DockerProvisioner) SetEngineOptions(engineOptions engine.Options) {
+	provisioner.EngineOptions = engineOptions
+}
+
 func (provisioner *Boot2DockerProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	if err := validateDockerPort(dockerPort); err != nil {
+		return nil, err
+	}
+
+	provisioner.EngineOptions.Labels = appendDefaultLabels(provisioner.EngineOptions, provisioner.Driver)
 
 	engineConfigTmpl := `
 EXTRA_ARGS='
@@ -165,7 +199,9 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	daemonOptsDir := path.Join(provisioner.GetDockerOptionsDir(), "profile")
 	return &DockerOptions{
@@ -183,6 +219,14 @@ func (provisioner *Boot2DockerProvisioner) SetOsReleaseInfo(info *OsRelease) {
 }
 
 func (provisioner *Boot2DockerProvisioner) GetOsReleaseInfo() (*OsRelease, error) {
+	if provisioner.OsReleaseInfo == nil {
+		info, err := fetchOsRelease(provisioner)
+		if err != nil {
+			return nil, err
+		}
+		provisioner.OsReleaseInfo = info
+	}
+
 	return provisioner.OsReleaseInfo, nil
 }
 
@@ -222,6 +266,10 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options,
 		}
 	}()
 
+	if engineOptions, err = resolveEngineConfigURL(engineOptions); err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -231,7 +279,9 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options,
 		provisioner.EngineOptions.StorageDriver = "aufs"
 	}
 
-	if err = provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err = provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
@@ -241,17 +291,23 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.Options,
 		return err
 	}
 
-	if err = makeDockerOptionsDir(provisioner); err != nil {
+	if err = provisionStep("options-dir", func() error {
+		return makeDockerOptionsDir(provisioner)
+	}); err != nil {
 		return err
 	}
 
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	if err = ConfigureAuth(provisioner); err != nil {
+	if err = provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	if err = configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err = provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
@@ -262,6 +318,12 @@ func (provisioner *Boot2DockerProvisioner) SSHCommand(args string) (string, erro
 	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
 }
 
+func (provisioner *Boot2DockerProvisioner) CollectDiagnostics() (string, error) {
+	return collectDiagnostics(provisioner, append([]string{
+		"sudo tail -n 200 /var/log/docker.log",
+	}, genericDiagnosticsCommands...))
+}
+
 func (provisioner *Boot2DockerProvisioner) GetDriver() drivers.Driver {
 	return provisioner.Driver
 }
@@ -0,0 +1,66 @@
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// caTrustStore is implemented by provisioners which can add certificates to
+// the host's trusted CAs.
+type caTrustStore interface {
+	// CATrustStore returns the directory CA certificates are added to and
+	// the command rebuilding the trusted CAs from it.
+	CATrustStore() (anchorsDir, updateCommand string)
+}
+
+func (provisioner *GenericProvisioner) CATrustStore() (string, string) {
+	return "/usr/local/share/ca-certificates", "sudo update-ca-certificates"
+}
+
+// installExtraCACerts copies the local PEM files to the host's trust store so
+// that the daemon trusts them, e.g. to pull through a TLS-intercepting proxy.
+func installExtraCACerts(p Provisioner, certPaths []string) error {
+	if len(certPaths) == 0 {
+		return nil
+	}
+
+	store, ok := p.(caTrustStore)
+	if !ok {
+		log.Warnf("Extra CA certificates are not supported by the %s provisioner, skipping them", p.String())
+		return nil
+	}
+	anchorsDir, updateCommand := store.CATrustStore()
+
+	files := []remoteFile{}
+	for _, certPath := range certPaths {
+		content, err := ioutil.ReadFile(certPath)
+		if err != nil {
+			return fmt.Errorf("error reading CA cert %s: %s", certPath, err)
+		}
+
+		// update-ca-certificates only picks up files ending in .crt
+		name := strings.TrimSuffix(filepath.Base(certPath), filepath.Ext(certPath)) + ".crt"
+		files = append(files, remoteFile{"CA cert " + certPath, content, path.Join(anchorsDir, name)})
+	}
+
+	log.Info("Adding the extra CA certs to the remote trust store...")
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", anchorsDir)); err != nil {
+		return fmt.Errorf("error creating the CA trust store directory %s: %s", anchorsDir, err)
+	}
+
+	if err := copyFilesToRemote(p, files); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(updateCommand); err != nil {
+		return fmt.Errorf("error updating the trusted CAs: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallExtraCACerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certPaths := []string{filepath.Join(dir, "corp-root.pem"), filepath.Join(dir, "proxy.crt")}
+	for _, certPath := range certPaths {
+		assert.NoError(t, ioutil.WriteFile(certPath, []byte("-----BEGIN CERTIFICATE-----"), 0600))
+	}
+
+	sshCmder := &recordingSSHCommander{}
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.SSHCommander = sshCmder
+
+	err = installExtraCACerts(p, certPaths)
+
+	assert.NoError(t, err)
+	assert.Len(t, sshCmder.commands, 4)
+	assert.Equal(t, "sudo mkdir -p /etc/pki/ca-trust/source/anchors", sshCmder.commands[0])
+
+	// the certs are copied concurrently
+	copies := []string{}
+	for _, command := range sshCmder.commands[1:3] {
+		copies = append(copies, command[strings.LastIndex(command, " | ")+3:])
+	}
+	sort.Strings(copies)
+	assert.Equal(t, []string{
+		"sudo tee /etc/pki/ca-trust/source/anchors/corp-root.crt",
+		"sudo tee /etc/pki/ca-trust/source/anchors/proxy.crt",
+	}, copies)
+
+	assert.Equal(t, "sudo update-ca-trust", sshCmder.commands[3])
+}
+
+func TestInstallExtraCACertsDefaultTrustStore(t *testing.T) {
+	certFile, err := ioutil.TempFile("", "corp-ca")
+	assert.NoError(t, err)
+	certFile.Close()
+	defer os.Remove(certFile.Name())
+
+	sshCmder := &recordingSSHCommander{}
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = sshCmder
+
+	err = installExtraCACerts(p, []string{certFile.Name()})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sudo mkdir -p /usr/local/share/ca-certificates", sshCmder.commands[0])
+	assert.True(t, strings.HasSuffix(sshCmder.commands[1], "| sudo tee /usr/local/share/ca-certificates/"+filepath.Base(certFile.Name())+".crt"))
+	assert.Equal(t, "sudo update-ca-certificates", sshCmder.commands[2])
+}
+
+func TestInstallExtraCACertsMissingFile(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.SSHCommander = sshCmder
+
+	err := installExtraCACerts(p, []string{"/nonexistent/corp-ca.pem"})
+
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "error reading CA cert /nonexistent/corp-ca.pem: "))
+	assert.Empty(t, sshCmder.commands)
+}
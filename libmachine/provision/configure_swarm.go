@@ -1,8 +1,10 @@
 package provision
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -16,9 +18,20 @@ import (
 
 func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.Options) error {
 	if !swarmOptions.IsSwarm {
+		log.Debug("Swarm is disabled, skipping its configuration")
 		return nil
 	}
 
+	if swarmOptions.Mode == swarm.ModeSwarm {
+		if p.GetEngineOptions().LiveRestore {
+			return errors.New("live-restore cannot be used with swarm-mode")
+		}
+		if swarmOptions.Master && len(swarmModeManagers(swarmOptions)) == 0 {
+			return initSwarmMode(p, swarmOptions)
+		}
+		return joinSwarmMode(p, swarmOptions)
+	}
+
 	log.Info("Configuring swarm...")
 
 	ip, err := p.GetDriver().GetIP()
@@ -120,20 +133,10 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 			},
 		}
 
-		cmdWorker := []string{
-			"join",
-			"--advertise",
-			advertiseInfo,
-		}
-		for _, option := range swarmOptions.ArbitraryJoinFlags {
-			cmdWorker = append(cmdWorker, "--"+option)
-		}
-		cmdWorker = append(cmdWorker, swarmOptions.Discovery)
-
 		swarmWorkerConfig := &dockerclient.ContainerConfig{
 			Image:      swarmOptions.Image,
 			Env:        swarmOptions.Env,
-			Cmd:        cmdWorker,
+			Cmd:        classicSwarmJoinCmd(swarmOptions, advertiseInfo),
 			HostConfig: workerHostConfig,
 		}
 		if swarmOptions.IsExperimental {
@@ -147,3 +150,135 @@ func configureSwarm(p Provisioner, swarmOptions swarm.Options, authOptions auth.
 	}
 	return nil
 }
+
+// classicSwarmJoinCmd returns the command the standalone swarm agent
+// container is started with.
+func classicSwarmJoinCmd(swarmOptions swarm.Options, advertiseInfo string) []string {
+	cmdWorker := []string{
+		"join",
+		"--advertise",
+		advertiseInfo,
+	}
+	for _, option := range swarmOptions.ArbitraryJoinFlags {
+		cmdWorker = append(cmdWorker, "--"+option)
+	}
+
+	//Discovery must be at end of command
+	return append(cmdWorker, swarmOptions.Discovery)
+}
+
+// SwarmOptionser is implemented by provisioners keeping the swarm options
+// they were provisioned with, with the ones resolved by the provisioning.
+type SwarmOptionser interface {
+	GetSwarmOptions() swarm.Options
+}
+
+// SwarmUnlockKeySetter is implemented by provisioners keeping the unlock key
+// of the swarm-mode cluster they locked.
+type SwarmUnlockKeySetter interface {
+	SetSwarmUnlockKey(unlockKey string)
+}
+
+var swarmUnlockKeyRE = regexp.MustCompile(`SWMKEY-1-[A-Za-z0-9+/=]+`)
+
+// parseSwarmUnlockKey returns the unlock key printed by docker swarm init
+// --autolock.
+func parseSwarmUnlockKey(output string) (string, error) {
+	unlockKey := swarmUnlockKeyRE.FindString(output)
+	if unlockKey == "" {
+		return "", errors.New("no unlock key found in the output of docker swarm init")
+	}
+
+	return unlockKey, nil
+}
+
+// initSwarmMode makes the engine the first manager of a new swarm-mode
+// cluster. When the cluster is locked, its unlock key is handed to the
+// provisioner for it to be persisted. The logs redact it, including in the
+// output of docker swarm init.
+func initSwarmMode(p Provisioner, swarmOptions swarm.Options) error {
+	cmd := "sudo docker swarm init"
+	if swarmOptions.Autolock {
+		cmd += " --autolock"
+	}
+
+	log.Info("Initializing swarm-mode cluster...")
+
+	output, err := p.SSHCommand(cmd)
+	if err != nil {
+		return fmt.Errorf("error initializing swarm-mode cluster: %s", err)
+	}
+
+	if !swarmOptions.Autolock {
+		return nil
+	}
+
+	unlockKey, err := parseSwarmUnlockKey(output)
+	if err != nil {
+		return err
+	}
+	log.RegisterSecret(unlockKey)
+
+	if setter, ok := p.(SwarmUnlockKeySetter); ok {
+		setter.SetSwarmUnlockKey(unlockKey)
+	}
+
+	return nil
+}
+
+// swarmModeManagers returns the swarm-mode managers to join, in the order
+// they are tried.
+func swarmModeManagers(swarmOptions swarm.Options) []string {
+	var managers []string
+	if swarmOptions.ManagerAddress != "" {
+		managers = append(managers, swarmOptions.ManagerAddress)
+	}
+
+	return append(managers, swarmOptions.ManagerAddresses...)
+}
+
+// swarmModeJoinCmd returns the command joining the engine to a swarm-mode
+// cluster through the manager at managerAddress.
+func swarmModeJoinCmd(swarmOptions swarm.Options, managerAddress string) (string, error) {
+	if swarmOptions.JoinToken == "" {
+		return "", errors.New("a join token is required to join a swarm-mode cluster")
+	}
+	if managerAddress == "" {
+		return "", errors.New("a manager address is required to join a swarm-mode cluster")
+	}
+
+	return fmt.Sprintf("sudo docker swarm join --token %s %s", swarmOptions.JoinToken, managerAddress), nil
+}
+
+// joinSwarmMode joins the engine to a swarm-mode cluster, trying the next
+// manager when one cannot be joined, e.g. because it is unreachable or has
+// lost its quorum. The join token is redacted from the logs, which show the
+// errors of the join commands.
+func joinSwarmMode(p Provisioner, swarmOptions swarm.Options) error {
+	log.RegisterSecret(swarmOptions.JoinToken)
+
+	managers := swarmModeManagers(swarmOptions)
+	if len(managers) == 0 {
+		managers = []string{""}
+	}
+
+	var joinErr error
+	for _, manager := range managers {
+		cmd, err := swarmModeJoinCmd(swarmOptions, manager)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Joining swarm-mode cluster at %s...", manager)
+
+		if _, err := p.SSHCommand(cmd); err != nil {
+			log.Warnf("Unable to join the swarm-mode manager at %s: %s", manager, err)
+			joinErr = err
+			continue
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("error joining swarm-mode cluster: %s", joinErr)
+}
@@ -0,0 +1,261 @@
+package provision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassicSwarmJoinCmd(t *testing.T) {
+	swarmOptions := swarm.Options{
+		Discovery:          "token://abc",
+		ArbitraryJoinFlags: []string{"heartbeat=5s"},
+	}
+
+	cmd := classicSwarmJoinCmd(swarmOptions, "10.0.0.5:2376")
+
+	assert.Equal(t, []string{"join", "--advertise", "10.0.0.5:2376", "--heartbeat=5s", "token://abc"}, cmd)
+}
+
+func TestSwarmModeJoinCmd(t *testing.T) {
+	swarmOptions := swarm.Options{
+		Mode:           swarm.ModeSwarm,
+		JoinToken:      "SWMTKN-1-abc",
+		ManagerAddress: "10.0.0.1:2377",
+	}
+
+	cmd, err := swarmModeJoinCmd(swarmOptions, "10.0.0.1:2377")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sudo docker swarm join --token SWMTKN-1-abc 10.0.0.1:2377", cmd)
+}
+
+func TestSwarmModeJoinCmdMissingToken(t *testing.T) {
+	_, err := swarmModeJoinCmd(swarm.Options{
+		Mode:           swarm.ModeSwarm,
+		ManagerAddress: "10.0.0.1:2377",
+	}, "10.0.0.1:2377")
+
+	assert.Error(t, err)
+}
+
+func TestConfigureSwarmDisabled(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: sshCmder}}
+
+	for _, swarmOptions := range []swarm.Options{
+		{},
+		{Discovery: "token://abc", Master: true, Agent: true},
+		{Mode: swarm.ModeSwarm, JoinToken: "SWMTKN-1-abc", ManagerAddress: "10.0.0.1:2377"},
+	} {
+		assert.NoError(t, configureSwarm(p, swarmOptions, p.AuthOptions))
+	}
+
+	assert.Empty(t, sshCmder.commands)
+}
+
+func TestConfigureSwarmMode(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: sshCmder}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm:        true,
+		Agent:          true,
+		Mode:           swarm.ModeSwarm,
+		JoinToken:      "SWMTKN-1-abc",
+		ManagerAddress: "10.0.0.1:2377",
+	}, p.AuthOptions)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sudo docker swarm join --token SWMTKN-1-abc 10.0.0.1:2377"}, sshCmder.commands)
+}
+
+func TestConfigureSwarmModeLiveRestore(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+	p := &fakeProvisioner{GenericProvisioner{
+		SSHCommander:  sshCmder,
+		EngineOptions: engine.Options{LiveRestore: true},
+	}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm:        true,
+		Mode:           swarm.ModeSwarm,
+		JoinToken:      "SWMTKN-1-abc",
+		ManagerAddress: "10.0.0.1:2377",
+	}, p.AuthOptions)
+
+	assert.EqualError(t, err, "live-restore cannot be used with swarm-mode")
+	assert.Empty(t, sshCmder.commands)
+}
+
+func TestConfigureSwarmModeNextManager(t *testing.T) {
+	sshCmder := &recordingSSHCommander{
+		errs: map[string]error{"10.0.0.1:2377": errors.New("Timeout was reached before node joined")},
+	}
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: sshCmder}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm:          true,
+		Mode:             swarm.ModeSwarm,
+		JoinToken:        "SWMTKN-1-abc",
+		ManagerAddresses: []string{"10.0.0.1:2377", "10.0.0.2:2377"},
+	}, p.AuthOptions)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"sudo docker swarm join --token SWMTKN-1-abc 10.0.0.1:2377",
+		"sudo docker swarm join --token SWMTKN-1-abc 10.0.0.2:2377",
+	}, sshCmder.commands)
+}
+
+func TestConfigureSwarmModeNoManagerReachable(t *testing.T) {
+	sshCmder := &recordingSSHCommander{
+		errs: map[string]error{"sudo docker swarm join": errors.New("Timeout was reached before node joined")},
+	}
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: sshCmder}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm:          true,
+		Mode:             swarm.ModeSwarm,
+		JoinToken:        "SWMTKN-1-abc",
+		ManagerAddress:   "10.0.0.1:2377",
+		ManagerAddresses: []string{"10.0.0.2:2377"},
+	}, p.AuthOptions)
+
+	assert.EqualError(t, err, "error joining swarm-mode cluster: Timeout was reached before node joined")
+	assert.Len(t, sshCmder.commands, 2)
+}
+
+func TestConfigureSwarmModeJoinTokenNotLogged(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetOutWriter(&logOutput)
+	log.SetErrWriter(&logOutput)
+	defer func() {
+		log.SetOutWriter(os.Stdout)
+		log.SetErrWriter(os.Stderr)
+	}()
+
+	joinToken := "SWMTKN-1-3pu6hszjas19xyp7ghgosyx9k8atbfcr8p2is99znpy26u2lkl-1awxwuwd3z9j1z3puu7rcgdbx"
+	sshCmder := &recordingSSHCommander{
+		errs: map[string]error{"10.0.0.1:2377": fmt.Errorf("Something went wrong running an SSH command!\ncommand : sudo docker swarm join --token %s 10.0.0.1:2377", joinToken)},
+	}
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: sshCmder}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm:          true,
+		Mode:             swarm.ModeSwarm,
+		JoinToken:        joinToken,
+		ManagerAddresses: []string{"10.0.0.1:2377", "10.0.0.2:2377"},
+	}, p.AuthOptions)
+
+	assert.NoError(t, err)
+	assert.Contains(t, logOutput.String(), "Unable to join the swarm-mode manager at 10.0.0.1:2377")
+	assert.NotContains(t, logOutput.String(), joinToken)
+	assert.NotContains(t, strings.Join(log.History(), "\n"), joinToken)
+}
+
+const swarmInitAutolockOutput = `Swarm initialized: current node (k1q27tfyx9rncpixhk69sa61v) is now a manager.
+
+To add a worker to this swarm, run the following command:
+
+    docker swarm join \
+    --token SWMTKN-1-0j52ln6hxjpxk2wgk917abcnxywj3xed0y8vi1e5m9t3uttrtu-7bnxvvlz2mrcpfonjuztmtts9 \
+    172.31.46.109:2377
+
+To add a manager to this swarm, run 'docker swarm join-token manager' and follow the instructions.
+
+To unlock a swarm manager after it restarts, run the ` + "`docker swarm unlock`" + `
+command and provide the following key:
+
+    SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8
+
+Please remember to store this key in a password manager, since without it you
+will not be able to restart the manager.
+`
+
+func TestParseSwarmUnlockKey(t *testing.T) {
+	unlockKey, err := parseSwarmUnlockKey(swarmInitAutolockOutput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8", unlockKey)
+
+	_, err = parseSwarmUnlockKey("Swarm initialized: current node (k1q27tfyx9rncpixhk69sa61v) is now a manager.\n")
+	assert.EqualError(t, err, "no unlock key found in the output of docker swarm init")
+}
+
+func TestConfigureSwarmModeInitAutolock(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"sudo docker swarm init --autolock": swarmInitAutolockOutput},
+	}}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm:  true,
+		Master:   true,
+		Mode:     swarm.ModeSwarm,
+		Autolock: true,
+	}, p.AuthOptions)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8", p.GetSwarmOptions().UnlockKey)
+}
+
+// outputLoggingSSHCommander logs the output of the commands like the SSH
+// commanders running them on the host.
+type outputLoggingSSHCommander struct {
+	provisiontest.FakeSSHCommander
+}
+
+func (sshCmder *outputLoggingSSHCommander) SSHCommand(args string) (string, error) {
+	output, err := sshCmder.FakeSSHCommander.SSHCommand(args)
+	log.Debugf("SSH cmd err, output: %v: %s", err, output)
+	return output, err
+}
+
+func TestInitSwarmModeUnlockKeyNotLogged(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetDebug(true)
+	log.SetErrWriter(&logOutput)
+	defer func() {
+		log.SetDebug(false)
+		log.SetErrWriter(os.Stderr)
+	}()
+
+	unlockKey := "SWMKEY-1-7c37Cc8654o6p38HnroywCi19pllOnGtbdZEgtKxZu8"
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: &outputLoggingSSHCommander{provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo docker swarm init --autolock": strings.Replace(swarmInitAutolockOutput, "SWMKEY-1-WuYH/IX284+lRcXuoVf38viIDK3HJEKY13MIHX+tTt8", unlockKey, 1),
+		},
+	}}}}
+
+	err := initSwarmMode(p, swarm.Options{Autolock: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, unlockKey, p.GetSwarmOptions().UnlockKey)
+	assert.Contains(t, logOutput.String(), "<REDACTED>")
+	assert.NotContains(t, logOutput.String(), unlockKey)
+	assert.NotContains(t, strings.Join(log.History(), "\n"), unlockKey)
+}
+
+func TestConfigureSwarmModeInit(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+	p := &fakeProvisioner{GenericProvisioner{SSHCommander: sshCmder}}
+
+	err := configureSwarm(p, swarm.Options{
+		IsSwarm: true,
+		Master:  true,
+		Mode:    swarm.ModeSwarm,
+	}, p.AuthOptions)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sudo docker swarm init"}, sshCmder.commands)
+	assert.Empty(t, p.GetSwarmOptions().UnlockKey)
+}
@@ -0,0 +1,110 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// ConfigURLTimeout bounds the request fetching EngineOptions.ConfigURL.
+var ConfigURLTimeout = 30 * time.Second
+
+// fetchEngineOptions gets the JSON encoded engine options served at url, along
+// with the names of the fields the JSON sets.
+func fetchEngineOptions(url string) (engine.Options, map[string]bool, error) {
+	var engineOptions engine.Options
+
+	client := &http.Client{Timeout: ConfigURLTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return engineOptions, nil, fmt.Errorf("error fetching engine options from %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return engineOptions, nil, fmt.Errorf("error fetching engine options from %s: %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return engineOptions, nil, fmt.Errorf("error fetching engine options from %s: %s", url, err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return engineOptions, nil, fmt.Errorf("error decoding engine options from %s: %s", url, err)
+	}
+
+	if err := json.Unmarshal(data, &engineOptions); err != nil {
+		return engineOptions, nil, fmt.Errorf("error decoding engine options from %s: %s", url, err)
+	}
+
+	return engineOptions, presentFields(reflect.TypeOf(engineOptions), keys), nil
+}
+
+// presentFields returns the names of the fields of the struct type t set by
+// keys, matched the way encoding/json does.
+func presentFields(t reflect.Type, keys map[string]json.RawMessage) map[string]bool {
+	present := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+			name = tag
+		}
+
+		for key := range keys {
+			if strings.EqualFold(key, name) {
+				present[field.Name] = true
+			}
+		}
+	}
+
+	return present
+}
+
+// mergeEngineOptions returns engineOptions with every field left empty set to
+// its value in remote, for the fields named in present. Booleans cannot be
+// told apart from ones left empty, so those set by remote are used as they
+// are.
+func mergeEngineOptions(engineOptions, remote engine.Options, present map[string]bool) engine.Options {
+	merged := reflect.ValueOf(&engineOptions).Elem()
+	remoteValue := reflect.ValueOf(remote)
+
+	for i := 0; i < merged.NumField(); i++ {
+		if !present[merged.Type().Field(i).Name] {
+			continue
+		}
+
+		field := merged.Field(i)
+		if field.Kind() == reflect.Bool || reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) {
+			field.Set(remoteValue.Field(i))
+		}
+	}
+
+	return engineOptions
+}
+
+// resolveEngineConfigURL merges the engine options served at
+// engineOptions.ConfigURL into engineOptions, the ones set by the user taking
+// precedence. Provisioners call it before using any of the engine options.
+func resolveEngineConfigURL(engineOptions engine.Options) (engine.Options, error) {
+	if engineOptions.ConfigURL == "" {
+		return engineOptions, nil
+	}
+
+	log.Infof("Fetching engine options from %s...", engineOptions.ConfigURL)
+	remote, present, err := fetchEngineOptions(engineOptions.ConfigURL)
+	if err != nil {
+		return engineOptions, err
+	}
+
+	return mergeEngineOptions(engineOptions, remote, present), nil
+}
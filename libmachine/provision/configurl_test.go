@@ -0,0 +1,90 @@
+package provision
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveEngineConfigURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Labels": ["dc=eu-west"], "InsecureRegistry": ["registry.corp:5000"], "StorageDriver": "devicemapper"}`)
+	}))
+	defer server.Close()
+
+	engineOptions, err := resolveEngineConfigURL(engine.Options{
+		ConfigURL:     server.URL,
+		StorageDriver: "overlay",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dc=eu-west"}, engineOptions.Labels)
+	assert.Equal(t, []string{"registry.corp:5000"}, engineOptions.InsecureRegistry)
+	assert.Equal(t, "overlay", engineOptions.StorageDriver)
+	assert.Equal(t, server.URL, engineOptions.ConfigURL)
+}
+
+func TestResolveEngineConfigURLBooleans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"DisableTLSVerify": false, "skipHostname": true}`)
+	}))
+	defer server.Close()
+
+	engineOptions, err := resolveEngineConfigURL(engine.Options{
+		ConfigURL:        server.URL,
+		DisableTLSVerify: true,
+		UseEnvFile:       true,
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, engineOptions.DisableTLSVerify)
+	assert.True(t, engineOptions.SkipHostname)
+	assert.True(t, engineOptions.UseEnvFile)
+}
+
+func TestProvisionEngineConfigURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"StorageDriver": "overlay2", "SkipHostname": true}`)
+	}))
+	defer server.Close()
+
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SSHCommander = &recordingSSHCommander{}
+	p.SetDryRun(true)
+
+	err := p.Provision(swarm.Options{}, authOptions, engine.Options{ConfigURL: server.URL})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "overlay2", p.EngineOptions.StorageDriver)
+
+	var unit string
+	for _, command := range p.SSHCommander.(*DryRunSSHCommander).Commands {
+		assert.NotContains(t, command, "hostname")
+		if strings.Contains(command, "ExecStart=") {
+			unit = command
+		}
+	}
+	assert.Contains(t, unit, "--storage-driver overlay2")
+}
+
+func TestResolveEngineConfigURLError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := resolveEngineConfigURL(engine.Options{ConfigURL: server.URL})
+
+	assert.EqualError(t, err, fmt.Sprintf("error fetching engine options from %s: 404 Not Found", server.URL))
+}
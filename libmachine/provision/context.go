@@ -0,0 +1,109 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"golang.org/x/net/context"
+)
+
+// ContextSetter is implemented by provisioners able to stop running commands
+// on the remote host once a context is done.
+type ContextSetter interface {
+	SetContext(ctx context.Context)
+}
+
+// ContextSSHCommander runs the commands of the SSHCommander it wraps until
+// its context is done, failing them with the context error from then on. A
+// command running when the context is done is not interrupted on the remote
+// host, but is no longer waited for.
+type ContextSSHCommander struct {
+	SSHCommander SSHCommander
+	Context      context.Context
+}
+
+func (cmder *ContextSSHCommander) SSHCommand(args string) (string, error) {
+	return cmder.run(func() (string, error) {
+		return cmder.SSHCommander.SSHCommand(args)
+	})
+}
+
+func (cmder *ContextSSHCommander) SSHCommandWithStdin(args string, stdin io.Reader) (string, error) {
+	stdinCmder, ok := cmder.SSHCommander.(StdinSSHCommander)
+	if !ok {
+		return "", fmt.Errorf("%T cannot feed the standard input of a command", cmder.SSHCommander)
+	}
+
+	return cmder.run(func() (string, error) {
+		return stdinCmder.SSHCommandWithStdin(args, stdin)
+	})
+}
+
+func (cmder *ContextSSHCommander) run(command func() (string, error)) (string, error) {
+	if err := cmder.Context.Err(); err != nil {
+		return "", err
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		output, err := command()
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-cmder.Context.Done():
+		return "", cmder.Context.Err()
+	}
+}
+
+// SetContext makes the commands of the provisioner fail once ctx is done, a
+// nil ctx running them unconditionally again.
+func (provisioner *GenericProvisioner) SetContext(ctx context.Context) {
+	cmder, ok := provisioner.SSHCommander.(*ContextSSHCommander)
+	switch {
+	case ctx != nil && ok:
+		cmder.Context = ctx
+	case ctx != nil:
+		provisioner.SSHCommander = &ContextSSHCommander{SSHCommander: provisioner.SSHCommander, Context: ctx}
+	case ok:
+		provisioner.SSHCommander = cmder.SSHCommander
+	}
+}
+
+// ProvisionContext provisions the host like Provision, returning the context
+// error as soon as ctx is done. The remaining commands of a ContextSetter are
+// then not run; other provisioners are left to complete in the background.
+func ProvisionContext(ctx context.Context, p Provisioner, swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	if setter, ok := p.(ContextSetter); ok {
+		setter.SetContext(ctx)
+		defer setter.SetContext(nil)
+
+		err := p.Provision(swarmOptions, authOptions, engineOptions)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Provision(swarmOptions, authOptions, engineOptions)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
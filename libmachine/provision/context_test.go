@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// cancelingSSHCommander cancels its context on the first command containing
+// cancelOn, then hangs on it like a wedged SSH session would.
+type cancelingSSHCommander struct {
+	cancelOn string
+	cancel   context.CancelFunc
+	release  chan struct{}
+	recordingSSHCommander
+}
+
+func (sshCmder *cancelingSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.recordingSSHCommander.SSHCommand(args)
+	if strings.Contains(args, sshCmder.cancelOn) {
+		sshCmder.cancel()
+		<-sshCmder.release
+	}
+	return "", nil
+}
+
+func TestProvisionContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sshCmder := &cancelingSSHCommander{cancelOn: "hostname", cancel: cancel, release: make(chan struct{})}
+	defer close(sshCmder.release)
+
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = sshCmder
+
+	err := ProvisionContext(ctx, p, swarm.Options{}, auth.Options{}, engine.Options{})
+
+	assert.Equal(t, context.Canceled, err)
+	// the hostname is set once the storage driver is decided, and nothing is
+	// run after it
+	assert.Len(t, sshCmder.commands, 2)
+	assert.Contains(t, sshCmder.commands[1], "hostname")
+	assert.Equal(t, sshCmder, p.SSHCommander)
+}
+
+func TestContextSSHCommanderDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sshCmder := &recordingSSHCommander{}
+	cmder := &ContextSSHCommander{SSHCommander: sshCmder, Context: ctx}
+
+	_, err := cmder.SSHCommand("hostname")
+	assert.NoError(t, err)
+
+	cancel()
+	_, err = cmder.SSHCommand("sudo systemctl -f restart docker")
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"hostname"}, sshCmder.commands)
+}
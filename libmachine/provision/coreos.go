@@ -26,6 +26,7 @@ func init() {
 	Register("CoreOS", &RegisteredProvisioner{
 		New: NewCoreOSProvisioner,
 	})
+	RegisterOsReleaseVariant("coreos", "coreos")
 }
 
 func NewCoreOSProvisioner(d drivers.Driver) Provisioner {
@@ -42,6 +43,10 @@ func (provisioner *CoreOSProvisioner) String() string {
 	return "coreOS"
 }
 
+func (provisioner *CoreOSProvisioner) CATrustStore() (string, string) {
+	return "/etc/ssl/certs", "sudo update-ca-certificates"
+}
+
 func (provisioner *CoreOSProvisioner) SetHostname(hostname string) error {
 	log.Debugf("SetHostname: %s", hostname)
 
@@ -61,8 +66,11 @@ func (provisioner *CoreOSProvisioner) GenerateDockerOptions(dockerPort int) (*Do
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	if err := validateDockerPort(dockerPort); err != nil {
+		return nil, err
+	}
+
+	provisioner.EngineOptions.Labels = appendDefaultLabels(provisioner.EngineOptions, provisioner.Driver)
 
 	engineConfigTmpl := `[Unit]
 Description=Docker Socket for the API
@@ -93,7 +101,9 @@ WantedBy=multi-user.target
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
@@ -106,28 +116,39 @@ func (provisioner *CoreOSProvisioner) Package(name string, action pkgaction.Pack
 }
 
 func (provisioner *CoreOSProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	if err := makeDockerOptionsDir(provisioner); err != nil {
+	if err := provisionStep("options-dir", func() error {
+		return makeDockerOptionsDir(provisioner)
+	}); err != nil {
 		return err
 	}
 
 	log.Debugf("Preparing certificates")
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	log.Debugf("Setting up certificates")
-	if err := ConfigureAuth(provisioner); err != nil {
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	log.Debug("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
@@ -82,6 +82,11 @@ func (provisioner *DebianProvisioner) dockerDaemonResponding() bool {
 }
 
 func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -99,8 +104,9 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
-	log.Debug("setting hostname")
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
@@ -123,13 +129,15 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	log.Debug("configuring auth")
-	if err := ConfigureAuth(provisioner); err != nil {
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	log.Debug("configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
@@ -0,0 +1,75 @@
+package provision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// genericDiagnosticsCommands are the read-only commands whose output is
+// collected from every host by CollectDiagnostics.
+var genericDiagnosticsCommands = []string{
+	"sudo docker version",
+	"sudo docker info",
+}
+
+// diagnosedHost is the part of a provisioner collectDiagnostics relies on.
+type diagnosedHost interface {
+	SSHCommander
+	GetDriver() drivers.Driver
+	GetOsReleaseInfo() (*OsRelease, error)
+}
+
+// collectDiagnostics runs the commands on the host and returns their output,
+// after a header naming the host and its OS so that the output of several
+// hosts can be told apart, each under a header naming the command. A failing
+// command only has its error recorded so the remaining ones still run; an
+// error is returned when none of them could be run.
+func collectDiagnostics(p diagnosedHost, commands []string) (string, error) {
+	var (
+		out    bytes.Buffer
+		failed int
+	)
+
+	fmt.Fprintf(&out, "Machine: %s\nOS: %s\n\n", p.GetDriver().GetMachineName(), describeOs(p))
+
+	for _, command := range commands {
+		output, err := p.SSHCommand(command)
+		fmt.Fprintf(&out, "==> %s <==\n%s", command, output)
+		if len(output) > 0 && output[len(output)-1] != '\n' {
+			out.WriteString("\n")
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(&out, "error: %s\n", err)
+		}
+		out.WriteString("\n")
+	}
+
+	if len(commands) > 0 && failed == len(commands) {
+		return out.String(), errors.New("unable to run any of the diagnostics commands on the host")
+	}
+
+	return out.String(), nil
+}
+
+func (provisioner *GenericProvisioner) CollectDiagnostics() (string, error) {
+	return collectDiagnostics(provisioner, genericDiagnosticsCommands)
+}
+
+// describeOs names the OS of the host from its os-release, or "unknown" when
+// it cannot be read.
+func describeOs(p diagnosedHost) string {
+	info, err := p.GetOsReleaseInfo()
+	if err != nil || info == nil {
+		return "unknown"
+	}
+
+	if info.PrettyName != "" {
+		return info.PrettyName
+	}
+
+	return fmt.Sprintf("%s %s", info.ID, info.VersionID)
+}
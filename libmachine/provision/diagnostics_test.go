@@ -0,0 +1,54 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemdCollectDiagnostics(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{MockName: "node-1"})
+	p.SetOsReleaseInfo(&OsRelease{ID: "centos", PrettyName: "CentOS Linux 7 (Core)"})
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo systemctl status docker --no-pager":     "docker.service - Docker Application Container Engine\n",
+			"sudo journalctl -u docker --no-pager -n 200": "level=fatal msg=\"Error starting daemon\"\n",
+			"sudo docker version":                         "Version: 1.10.3",
+		},
+	}
+
+	out, err := p.CollectDiagnostics()
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out, "Machine: node-1\nOS: CentOS Linux 7 (Core)\n\n"))
+	assert.Contains(t, out, "==> sudo systemctl status docker --no-pager <==\ndocker.service - Docker Application Container Engine\n\n")
+	assert.Contains(t, out, "==> sudo journalctl -u docker --no-pager -n 200 <==\nlevel=fatal msg=\"Error starting daemon\"\n\n")
+	assert.Contains(t, out, "==> sudo docker version <==\nVersion: 1.10.3\n\n")
+	assert.Contains(t, out, "==> sudo docker info <==\nerror: Command not registered in FakeSSHCommander\n\n")
+}
+
+func TestCollectDiagnosticsUnreachableHost(t *testing.T) {
+	p := NewUbuntuProvisioner(&fakedriver.Driver{})
+	p.(*UbuntuProvisioner).SSHCommander = &provisiontest.FakeSSHCommander{}
+
+	_, err := p.CollectDiagnostics()
+
+	assert.EqualError(t, err, "unable to run any of the diagnostics commands on the host")
+}
+
+func TestCollectDiagnosticsUnknownOs(t *testing.T) {
+	p := NewUbuntuProvisioner(&fakedriver.Driver{MockName: "node-2"})
+	p.(*UbuntuProvisioner).SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo docker version": "Version: 1.10.3",
+		},
+	}
+
+	out, err := p.CollectDiagnostics()
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out, "Machine: node-2\nOS: unknown\n\n==> "))
+}
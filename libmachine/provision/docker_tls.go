@@ -0,0 +1,78 @@
+package provision
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const dockerTLSCheckTimeout = 10 * time.Second
+
+// checkDockerTLS calls the version endpoint of the daemon at addr with a
+// client authenticating through the generated client cert, and verifying the
+// daemon's cert against the CA. An open port is not enough for clients to be
+// able to talk to the daemon: this catches certs copied to the wrong paths or
+// generated for the wrong host.
+func checkDockerTLS(addr string, authOptions auth.Options) error {
+	tlsConfig, err := clientTLSConfig(authOptions)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout:   dockerTLSCheckTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	log.Debugf("Checking the TLS connection to the daemon at %s", addr)
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/version", addr))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the daemon answered %s", resp.Status)
+	}
+
+	var version struct {
+		Version string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return fmt.Errorf("the daemon sent an invalid version: %s", err)
+	}
+
+	log.Debugf("The daemon at %s runs Docker %s", addr, version.Version)
+	return nil
+}
+
+func clientTLSConfig(authOptions auth.Options) (*tls.Config, error) {
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("There was an error reading certificate")
+	}
+
+	keypair, err := tls.LoadX509KeyPair(authOptions.ClientCertPath, authOptions.ClientKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		RootCAs:      certPool,
+		Certificates: []tls.Certificate{keypair},
+	}, nil
+}
@@ -0,0 +1,123 @@
+package provision
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/stretchr/testify/assert"
+)
+
+// generateTestCerts generates a CA, the cert of a server on 127.0.0.1 and a
+// client cert in dir.
+func generateTestCerts(t *testing.T, dir string) auth.Options {
+	authOptions := auth.Options{
+		CaCertPath:       filepath.Join(dir, "ca.pem"),
+		CaPrivateKeyPath: filepath.Join(dir, "ca-key.pem"),
+		ServerCertPath:   filepath.Join(dir, "server.pem"),
+		ServerKeyPath:    filepath.Join(dir, "server-key.pem"),
+		ClientCertPath:   filepath.Join(dir, "cert.pem"),
+		ClientKeyPath:    filepath.Join(dir, "key.pem"),
+	}
+
+	if err := cert.GenerateCACertificate(authOptions.CaCertPath, authOptions.CaPrivateKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.GenerateCert([]string{"127.0.0.1"}, authOptions.ServerCertPath, authOptions.ServerKeyPath, authOptions.CaCertPath, authOptions.CaPrivateKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.GenerateCert([]string{""}, authOptions.ClientCertPath, authOptions.ClientKeyPath, authOptions.CaCertPath, authOptions.CaPrivateKeyPath, "test-org", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	return authOptions
+}
+
+// newTestDaemon starts a TLS server answering the version endpoint to the
+// clients with a cert signed by the CA of authOptions.
+func newTestDaemon(t *testing.T, authOptions auth.Options) *httptest.Server {
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caCert)
+
+	serverCert, err := tls.LoadX509KeyPair(authOptions.ServerCertPath, authOptions.ServerKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"Version":"1.12.0"}`)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+
+	return server
+}
+
+func TestCheckDockerTLS(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	authOptions := generateTestCerts(t, tmpDir)
+	server := newTestDaemon(t, authOptions)
+	defer server.Close()
+
+	err = checkDockerTLS(strings.TrimPrefix(server.URL, "https://"), authOptions)
+
+	assert.NoError(t, err)
+}
+
+func TestCheckDockerTLSOtherCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.Mkdir(filepath.Join(tmpDir, "daemon"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpDir, "client"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestDaemon(t, generateTestCerts(t, filepath.Join(tmpDir, "daemon")))
+	defer server.Close()
+
+	err = checkDockerTLS(strings.TrimPrefix(server.URL, "https://"), generateTestCerts(t, filepath.Join(tmpDir, "client")))
+
+	assert.Error(t, err)
+}
+
+func TestCheckDockerTLSMissingCerts(t *testing.T) {
+	err := checkDockerTLS("127.0.0.1:2376", auth.Options{
+		CaCertPath:     "/nonexistent/ca.pem",
+		ClientCertPath: "/nonexistent/cert.pem",
+		ClientKeyPath:  "/nonexistent/key.pem",
+	})
+
+	assert.Error(t, err)
+}
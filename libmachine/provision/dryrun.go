@@ -0,0 +1,59 @@
+package provision
+
+import (
+	"io"
+	"sync"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// DryRunner is implemented by provisioners able to log and record the
+// commands they would run on the remote host instead of running them.
+type DryRunner interface {
+	SetDryRun(dryRun bool)
+	DryRun() bool
+}
+
+// DryRunSSHCommander records commands in place of the SSHCommander it wraps
+// and returns an empty output for each of them.
+type DryRunSSHCommander struct {
+	SSHCommander SSHCommander
+	Commands     []string
+	mu           sync.Mutex
+}
+
+func (cmder *DryRunSSHCommander) SSHCommand(args string) (string, error) {
+	cmder.mu.Lock()
+	defer cmder.mu.Unlock()
+
+	log.Infof("(dry-run) %s", args)
+	cmder.Commands = append(cmder.Commands, args)
+	return "", nil
+}
+
+// SSHCommandWithStdin records args like SSHCommand, leaving stdin unread.
+func (cmder *DryRunSSHCommander) SSHCommandWithStdin(args string, stdin io.Reader) (string, error) {
+	return cmder.SSHCommand(args)
+}
+
+// SetDryRun switches the provisioner between running its commands over SSH
+// and only recording them.
+func (provisioner *GenericProvisioner) SetDryRun(dryRun bool) {
+	cmder, ok := provisioner.SSHCommander.(*DryRunSSHCommander)
+	switch {
+	case dryRun && !ok:
+		provisioner.SSHCommander = &DryRunSSHCommander{SSHCommander: provisioner.SSHCommander}
+	case !dryRun && ok:
+		provisioner.SSHCommander = cmder.SSHCommander
+	}
+}
+
+func (provisioner *GenericProvisioner) DryRun() bool {
+	_, ok := provisioner.SSHCommander.(*DryRunSSHCommander)
+	return ok
+}
+
+func isDryRun(p SSHCommander) bool {
+	dr, ok := p.(DryRunner)
+	return ok && dr.DryRun()
+}
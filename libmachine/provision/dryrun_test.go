@@ -0,0 +1,50 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDryRun(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	recorder := &recordingSSHCommander{}
+	p.SSHCommander = recorder
+
+	p.SetDryRun(true)
+	p.SetDryRun(true)
+	assert.True(t, p.DryRun())
+	assert.Equal(t, recorder, p.SSHCommander.(*DryRunSSHCommander).SSHCommander)
+
+	p.SetDryRun(false)
+	assert.False(t, p.DryRun())
+	assert.Equal(t, recorder, p.SSHCommander)
+}
+
+func TestProvisionDryRun(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	recorder := &recordingSSHCommander{}
+	p.SSHCommander = recorder
+	p.SetDryRun(true)
+
+	err := p.Provision(swarm.Options{}, authOptions, engine.Options{})
+	assert.NoError(t, err)
+
+	err = p.Package("docker", pkgaction.Upgrade)
+	assert.NoError(t, err)
+
+	assert.Empty(t, recorder.commands)
+
+	commands := p.SSHCommander.(*DryRunSSHCommander).Commands
+	assert.Contains(t, commands, "sudo -E yum -y update")
+	assert.Contains(t, commands, "sudo systemctl -f start docker")
+	assert.Contains(t, commands, "sudo -E yum upgrade -y docker")
+}
@@ -7,6 +7,17 @@ import (
 
 type EngineConfigContext struct {
 	DockerPort       int
+	ListenAddress    string
+	MountFlags       string
+	DaemonCommand    string
+	DataRootFlag     string
+	SocketActivation bool
+	Limits           []UnitLimit
+	Description      string
+	Documentation    string
+	After            string
+	Requires         string
+	WantedBy         string
 	AuthOptions      auth.Options
 	EngineOptions    engine.Options
 	DockerOptionsDir string
@@ -3,6 +3,7 @@ package provision
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -22,3 +23,24 @@ func NewErrDaemonAvailable(err error) ErrDaemonAvailable {
 		wrappedErr: err,
 	}
 }
+
+// ErrOsReleaseParse is returned when the OS of a host cannot be told from its
+// /etc/os-release, because the file is either missing or malformed.
+type ErrOsReleaseParse struct {
+	Reason string
+}
+
+func (e ErrOsReleaseParse) Error() string {
+	return fmt.Sprintf("unable to determine the OS from /etc/os-release: %s", e.Reason)
+}
+
+// ErrSSHCommandTimeout is returned when an SSH command did not complete in
+// the time it was given.
+type ErrSSHCommandTimeout struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (e ErrSSHCommandTimeout) Error() string {
+	return fmt.Sprintf("SSH command %q did not complete within %s", e.Command, e.Timeout)
+}
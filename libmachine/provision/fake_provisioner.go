@@ -43,6 +43,22 @@ func (fp *FakeProvisioner) GetAuthOptions() auth.Options {
 	return auth.Options{}
 }
 
+func (fp *FakeProvisioner) GetEngineOptions() engine.Options {
+	return engine.Options{}
+}
+
+func (fp *FakeProvisioner) EnsureDockerConfig(dockerOptions DockerOptions) (bool, error) {
+	return false, nil
+}
+
+func (fp *FakeProvisioner) GetDockerVersion() (string, error) {
+	return "", nil
+}
+
+func (fp *FakeProvisioner) CollectDiagnostics() (string, error) {
+	return "", nil
+}
+
 func (fp *FakeProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	return nil
 }
@@ -0,0 +1,198 @@
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// packageManager describes how to drive a distribution's package manager
+// over SSH.
+type packageManager struct {
+	// Command run before installing or upgrading packages, if any.
+	Refresh string
+	// Format string taking the action and the package name.
+	Command string
+	Actions map[pkgaction.PackageAction]string
+}
+
+var fallbackPackageManagers = map[string]packageManager{
+	"apt": {
+		Refresh: "sudo apt-get update",
+		Command: "DEBIAN_FRONTEND=noninteractive sudo -E apt-get %s -y %s",
+		Actions: map[pkgaction.PackageAction]string{
+			pkgaction.Install: "install",
+			pkgaction.Remove:  "remove",
+			pkgaction.Upgrade: "install",
+		},
+	},
+	"yum": {
+		Command: "sudo -E yum %s -y %s",
+		Actions: map[pkgaction.PackageAction]string{
+			pkgaction.Install: "install",
+			pkgaction.Remove:  "remove",
+			pkgaction.Upgrade: "upgrade",
+		},
+	},
+	"zypper": {
+		Refresh: "sudo zypper ref",
+		Command: "sudo -E zypper -n %s %s",
+		Actions: map[pkgaction.PackageAction]string{
+			pkgaction.Install: "install",
+			pkgaction.Remove:  "remove",
+			pkgaction.Upgrade: "update",
+		},
+	},
+}
+
+// FallbackProvisioner provisions systemd hosts whose OS no registered
+// provisioner recognizes, using the package manager the user hinted at.
+type FallbackProvisioner struct {
+	SystemdProvisioner
+	PackageManager string
+}
+
+// NewFallbackProvisioner returns a provisioner for an unrecognized systemd
+// host driven by the given package manager hint (apt, yum or zypper).
+func NewFallbackProvisioner(hint string, d drivers.Driver) (*FallbackProvisioner, error) {
+	if _, ok := fallbackPackageManagers[hint]; !ok {
+		return nil, fmt.Errorf("unknown provisioner hint %q, expected one of: %s", hint, strings.Join(fallbackProvisionerHints(), ", "))
+	}
+
+	return &FallbackProvisioner{
+		SystemdProvisioner: NewSystemdProvisioner("", d),
+		PackageManager:     hint,
+	}, nil
+}
+
+func fallbackProvisionerHints() []string {
+	hints := []string{}
+	for hint := range fallbackPackageManagers {
+		hints = append(hints, hint)
+	}
+	sort.Strings(hints)
+	return hints
+}
+
+// DetectProvisionerWithHint detects the provisioner for the host, falling
+// back to a FallbackProvisioner using hint when the OS is not recognized or
+// cannot be determined.
+func DetectProvisionerWithHint(d drivers.Driver, hint string) (Provisioner, error) {
+	provisioner, err := DetectProvisioner(d)
+	if _, unparsable := err.(ErrOsReleaseParse); (err != ErrDetectionFailed && !unparsable) || hint == "" {
+		return provisioner, err
+	}
+
+	log.Infof("OS type not recognized, falling back to a generic systemd provisioner using %s", hint)
+	fallback, err := NewFallbackProvisioner(hint, d)
+	if err != nil {
+		return nil, err
+	}
+
+	configureSudoCommand(fallback, d)
+	return fallback, nil
+}
+
+func (provisioner *FallbackProvisioner) String() string {
+	return fmt.Sprintf("generic(%s)", provisioner.PackageManager)
+}
+
+func (provisioner *FallbackProvisioner) CompatibleWithHost() bool {
+	// never picked during detection, only as an explicit fallback
+	return false
+}
+
+func (provisioner *FallbackProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	pm := fallbackPackageManagers[provisioner.PackageManager]
+
+	if pm.Refresh != "" && action != pkgaction.Remove {
+		if _, err := provisioner.SSHCommand(pm.Refresh); err != nil {
+			return err
+		}
+	}
+
+	command := fmt.Sprintf(pm.Command, pm.Actions[action], name)
+
+	log.Debugf("package: action=%s name=%s", action.String(), name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *FallbackProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+	swarmOptions.Env = engineOptions.Env
+
+	storageDriver, err := decideStorageDriver(provisioner, "overlay", engineOptions.StorageDriver)
+	if err != nil {
+		return err
+	}
+	provisioner.EngineOptions.StorageDriver = storageDriver
+
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
+		return err
+	}
+
+	log.Debug("installing base packages")
+	for _, pkg := range provisioner.Packages {
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	log.Debug("installing docker")
+	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+		return err
+	}
+
+	if err := ensureStorageDriverModule(provisioner, provisioner.EngineOptions.StorageDriver); err != nil {
+		return err
+	}
+
+	if err := provisionStep("options-dir", func() error {
+		return makeDockerOptionsDir(provisioner)
+	}); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
+		return err
+	}
+
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", serviceaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
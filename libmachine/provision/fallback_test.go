@@ -0,0 +1,134 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/stretchr/testify/assert"
+)
+
+// osReleaseDetector runs the standard matching logic against a fixed
+// os-release instead of one read over SSH.
+type osReleaseDetector struct {
+	osRelease string
+}
+
+func (detector *osReleaseDetector) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	osReleaseInfo, err := NewOsRelease([]byte(detector.osRelease))
+	if err != nil {
+		return nil, err
+	}
+	return detectFromOsRelease(d, osReleaseInfo)
+}
+
+func TestDetectProvisionerWithHintFallsBack(t *testing.T) {
+	defer SetDetector(&StandardDetector{})
+	SetDetector(&osReleaseDetector{osRelease: "ID=unknownlinux\n"})
+
+	provisioner, err := DetectProvisionerWithHint(&fakedriver.Driver{}, "zypper")
+
+	assert.NoError(t, err)
+	assert.IsType(t, &FallbackProvisioner{}, provisioner)
+	assert.Equal(t, "generic(zypper)", provisioner.String())
+}
+
+func TestDetectProvisionerWithHintUnparsableOsRelease(t *testing.T) {
+	defer SetDetector(&StandardDetector{})
+	SetDetector(&osReleaseDetector{osRelease: "garbage\n"})
+
+	_, err := DetectProvisionerWithHint(&fakedriver.Driver{}, "")
+
+	assert.Equal(t, ErrOsReleaseParse{Reason: "no ID found"}, err)
+
+	provisioner, err := DetectProvisionerWithHint(&fakedriver.Driver{}, "apt")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "generic(apt)", provisioner.String())
+}
+
+func TestDetectProvisionerWithoutHint(t *testing.T) {
+	defer SetDetector(&StandardDetector{})
+	SetDetector(&osReleaseDetector{osRelease: "ID=unknownlinux\n"})
+
+	_, err := DetectProvisionerWithHint(&fakedriver.Driver{}, "")
+
+	assert.Equal(t, ErrDetectionFailed, err)
+}
+
+func TestDetectProvisionerWithHintPrefersKnownOS(t *testing.T) {
+	defer SetDetector(&StandardDetector{})
+	SetDetector(&osReleaseDetector{osRelease: "ID=fedora\n"})
+
+	provisioner, err := DetectProvisionerWithHint(&fakedriver.Driver{}, "apt")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "fedora", provisioner.String())
+}
+
+func TestNewFallbackProvisionerUnknownHint(t *testing.T) {
+	_, err := NewFallbackProvisioner("pacman", &fakedriver.Driver{})
+
+	assert.EqualError(t, err, `unknown provisioner hint "pacman", expected one of: apt, yum, zypper`)
+}
+
+func TestFallbackProvisionerPackage(t *testing.T) {
+	var tests = []struct {
+		hint     string
+		commands []string
+	}{
+		{"apt", []string{"sudo apt-get update", "DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y curl"}},
+		{"yum", []string{"sudo -E yum install -y curl"}},
+		{"zypper", []string{"sudo zypper ref", "sudo -E zypper -n install curl"}},
+	}
+
+	for _, test := range tests {
+		sshCmder := &recordingSSHCommander{}
+		p, err := NewFallbackProvisioner(test.hint, &fakedriver.Driver{})
+		assert.NoError(t, err)
+		p.SSHCommander = sshCmder
+
+		assert.NoError(t, p.Package("curl", pkgaction.Install))
+		assert.Equal(t, test.commands, sshCmder.commands)
+	}
+}
+
+func TestFallbackInstallDockerVersion(t *testing.T) {
+	p, err := NewFallbackProvisioner("apt", &fakedriver.Driver{})
+	assert.NoError(t, err)
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+	p.EngineOptions = engine.Options{InstallURL: "https://get.docker.com", Version: "17.03"}
+
+	err = installDockerGeneric(p, p.EngineOptions.InstallURL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"if ! type docker; then curl -sSL https://get.docker.com | VERSION=17.03 sh -; fi"}, sshCmder.commands)
+}
+
+func TestFallbackInstallDockerInvalidURL(t *testing.T) {
+	p, err := NewFallbackProvisioner("apt", &fakedriver.Driver{})
+	assert.NoError(t, err)
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	err = installDockerGeneric(p, "file:///tmp/install.sh")
+
+	assert.EqualError(t, err, `invalid engine install URL "file:///tmp/install.sh": the scheme must be http or https`)
+	assert.Empty(t, sshCmder.commands)
+}
+
+func TestFallbackInstallDockerInvalidVersion(t *testing.T) {
+	p, err := NewFallbackProvisioner("apt", &fakedriver.Driver{})
+	assert.NoError(t, err)
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+	p.EngineOptions = engine.Options{Version: "17.03; reboot"}
+
+	err = installDockerGeneric(p, "https://get.docker.com")
+
+	assert.EqualError(t, err, `invalid Docker version "17.03; reboot"`)
+	assert.Empty(t, sshCmder.commands)
+}
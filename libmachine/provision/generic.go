@@ -3,6 +3,7 @@ package provision
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
@@ -22,6 +23,9 @@ type GenericProvisioner struct {
 	AuthOptions       auth.Options
 	EngineOptions     engine.Options
 	SwarmOptions      swarm.Options
+
+	PreProvisionHooks  []ProvisionHook
+	PostProvisionHooks []ProvisionHook
 }
 
 type GenericSSHCommander struct {
@@ -32,6 +36,10 @@ func (sshCmder GenericSSHCommander) SSHCommand(args string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(sshCmder.Driver, args)
 }
 
+func (sshCmder GenericSSHCommander) SSHCommandWithStdin(args string, stdin io.Reader) (string, error) {
+	return drivers.RunSSHCommandWithStdinFromDriver(sshCmder.Driver, args, stdin)
+}
+
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
@@ -76,11 +84,56 @@ func (provisioner *GenericProvisioner) GetAuthOptions() auth.Options {
 	return provisioner.AuthOptions
 }
 
+func (provisioner *GenericProvisioner) GetEngineOptions() engine.Options {
+	return provisioner.EngineOptions
+}
+
+func (provisioner *GenericProvisioner) GetSwarmOptions() swarm.Options {
+	return provisioner.SwarmOptions
+}
+
+func (provisioner *GenericProvisioner) SetSwarmUnlockKey(unlockKey string) {
+	provisioner.SwarmOptions.UnlockKey = unlockKey
+}
+
+func (provisioner *GenericProvisioner) GetDockerVersion() (string, error) {
+	return getDockerVersion(provisioner)
+}
+
+// SetDaemonOptionsFile sets the path GenerateDockerOptions writes the daemon
+// options to, for hosts installing docker.service elsewhere.
+func (provisioner *GenericProvisioner) SetDaemonOptionsFile(path string) {
+	provisioner.DaemonOptionsFile = path
+}
+
+func (provisioner *GenericProvisioner) EnsureDockerConfig(dockerOptions DockerOptions) (bool, error) {
+	return ensureDockerConfig(provisioner, dockerOptions)
+}
+
+func (provisioner *GenericProvisioner) SetAuthOptions(authOptions auth.Options) {
+	provisioner.AuthOptions = authOptions
+}
+
+func (provisioner *GenericProvisioner) SetEngineOptions(engineOptions engine.Options) {
+	provisioner.EngineOptions = engineOptions
+}
+
 func (provisioner *GenericProvisioner) SetOsReleaseInfo(info *OsRelease) {
 	provisioner.OsReleaseInfo = info
 }
 
+// GetOsReleaseInfo returns the os-release of the host, which is only read
+// over SSH when it was neither set at detection nor read before. Setting it
+// to nil makes it read again, e.g. once the host rebooted on a new release.
 func (provisioner *GenericProvisioner) GetOsReleaseInfo() (*OsRelease, error) {
+	if provisioner.OsReleaseInfo == nil {
+		info, err := fetchOsRelease(provisioner)
+		if err != nil {
+			return nil, err
+		}
+		provisioner.OsReleaseInfo = info
+	}
+
 	return provisioner.OsReleaseInfo, nil
 }
 
@@ -89,15 +142,18 @@ func (provisioner *GenericProvisioner) GenerateDockerOptions(dockerPort int) (*D
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	if err := validateDockerPort(dockerPort); err != nil {
+		return nil, err
+	}
+
+	provisioner.EngineOptions.Labels = appendDefaultLabels(provisioner.EngineOptions, provisioner.Driver)
 
 	engineConfigTmpl := `
 DOCKER_OPTS='
 -H tcp://0.0.0.0:{{.DockerPort}}
 -H unix:///var/run/docker.sock
---storage-driver {{.EngineOptions.StorageDriver}}
---tlsverify
+{{ if .EngineOptions.StorageDriver }}--storage-driver {{.EngineOptions.StorageDriver}}
+{{ end }}--tlsverify
 --tlscacert {{.AuthOptions.CaCertRemotePath}}
 --tlscert {{.AuthOptions.ServerCertRemotePath}}
 --tlskey {{.AuthOptions.ServerKeyRemotePath}}
@@ -121,7 +177,9 @@ DOCKER_OPTS='
 		EngineOptions: provisioner.EngineOptions,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	return &DockerOptions{
 		EngineOptions:     engineCfg.String(),
@@ -0,0 +1,34 @@
+package provision
+
+import "fmt"
+
+// ProvisionHook runs custom commands on a host while it is provisioned.
+type ProvisionHook func(p Provisioner) error
+
+type provisionHooker interface {
+	provisionHooks() (pre, post []ProvisionHook)
+}
+
+// provisionHooks returns the hooks ConfigureAuth runs: PreProvisionHooks
+// before the certs are set up, PostProvisionHooks once the daemon has been
+// restarted with them.
+func (provisioner *GenericProvisioner) provisionHooks() (pre, post []ProvisionHook) {
+	return provisioner.PreProvisionHooks, provisioner.PostProvisionHooks
+}
+
+func getProvisionHooks(p Provisioner) (pre, post []ProvisionHook) {
+	if hooker, ok := p.(provisionHooker); ok {
+		return hooker.provisionHooks()
+	}
+	return nil, nil
+}
+
+func runProvisionHooks(p Provisioner, phase string, hooks []ProvisionHook) error {
+	for i, hook := range hooks {
+		if err := hook(p); err != nil {
+			return fmt.Errorf("error running %s hook %d: %s", phase, i+1, err)
+		}
+	}
+
+	return nil
+}
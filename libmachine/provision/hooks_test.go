@@ -0,0 +1,50 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func recordingHook(command string) ProvisionHook {
+	return func(p Provisioner) error {
+		_, err := p.SSHCommand(command)
+		return err
+	}
+}
+
+func TestConfigureAuthRunsProvisionHooks(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.AuthOptions = authOptions
+	p.PreProvisionHooks = []ProvisionHook{recordingHook("pre-hook 1"), recordingHook("pre-hook 2")}
+	p.PostProvisionHooks = []ProvisionHook{recordingHook("post-hook")}
+	p.SetDryRun(true)
+
+	err := ConfigureAuth(p)
+
+	assert.NoError(t, err)
+	commands := p.SSHCommander.(*DryRunSSHCommander).Commands
+	assert.Equal(t, []string{"pre-hook 1", "pre-hook 2"}, commands[:2])
+	assert.Contains(t, commands, "sudo systemctl -f stop docker")
+	assert.Equal(t, "sudo systemctl -f start docker", commands[len(commands)-2])
+	assert.Equal(t, "post-hook", commands[len(commands)-1])
+}
+
+func TestConfigureAuthPreProvisionHookError(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.PreProvisionHooks = []ProvisionHook{func(p Provisioner) error {
+		return errors.New("agent install failed")
+	}}
+	p.SetDryRun(true)
+
+	err := ConfigureAuth(p)
+
+	assert.EqualError(t, err, "error running pre-provision hook 1: agent install failed")
+	assert.Empty(t, p.SSHCommander.(*DryRunSSHCommander).Commands)
+}
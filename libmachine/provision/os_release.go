@@ -24,6 +24,7 @@ type OsRelease struct {
 	IDLike       string `osr:"ID_LIKE"`
 	PrettyName   string `osr:"PRETTY_NAME"`
 	VersionID    string `osr:"VERSION_ID"`
+	VariantID    string `osr:"VARIANT_ID"`
 	HomeURL      string `osr:"HOME_URL"`
 	SupportURL   string `osr:"SUPPORT_URL"`
 	BugReportURL string `osr:"BUG_REPORT_URL"`
@@ -64,7 +65,13 @@ func parseLine(osrLine string) (string, string, error) {
 	return key, val, nil
 }
 
+// ParseOsRelease sets the values found in osReleaseContents. Invalid lines are
+// skipped, but an ErrOsReleaseParse is returned when no ID is found.
 func (osr *OsRelease) ParseOsRelease(osReleaseContents []byte) error {
+	if len(bytes.TrimSpace(osReleaseContents)) == 0 {
+		return ErrOsReleaseParse{Reason: "the file is missing or empty"}
+	}
+
 	r := bytes.NewReader(osReleaseContents)
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -77,9 +84,28 @@ func (osr *OsRelease) ParseOsRelease(osReleaseContents []byte) error {
 			log.Debug(err)
 		}
 	}
+
+	if osr.ID == "" {
+		return ErrOsReleaseParse{Reason: "no ID found"}
+	}
 	return nil
 }
 
+// osReleaseCommand prints the /etc/os-release of the host. A missing file is
+// left for the parsing to report, so that it is told apart from an SSH
+// failure.
+const osReleaseCommand = "cat /etc/os-release 2>/dev/null || true"
+
+// fetchOsRelease reads and parses the /etc/os-release of the host.
+func fetchOsRelease(p SSHCommander) (*OsRelease, error) {
+	osReleaseOut, err := p.SSHCommand(osReleaseCommand)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting SSH command: %s", err)
+	}
+
+	return NewOsRelease([]byte(osReleaseOut))
+}
+
 func NewOsRelease(contents []byte) (*OsRelease, error) {
 	osr := &OsRelease{}
 	if err := osr.ParseOsRelease(contents); err != nil {
@@ -5,6 +5,16 @@ import (
 	"testing"
 )
 
+func TestParseOsReleaseNoID(t *testing.T) {
+	for _, contents := range []string{"", "\n", "<html>503 Service Unavailable</html>\n", "NAME=Linux\n"} {
+		_, err := NewOsRelease([]byte(contents))
+
+		if _, ok := err.(ErrOsReleaseParse); !ok {
+			t.Fatalf("Expected an ErrOsReleaseParse parsing %q, got %v", contents, err)
+		}
+	}
+}
+
 func TestParseOsRelease(t *testing.T) {
 	// These example osr files stolen shamelessly from
 	// https://github.com/docker/docker/blob/master/pkg/parsers/operatingsystem/operatingsystem_test.go
@@ -0,0 +1,47 @@
+package provision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+// optionsSetter is implemented by provisioners whose options can be set
+// without running Provision.
+type optionsSetter interface {
+	SetAuthOptions(authOptions auth.Options)
+	SetEngineOptions(engineOptions engine.Options)
+}
+
+// PreviewDockerOptions returns the daemon options the named provisioner
+// would write to a host using the driver, without connecting to the host.
+func PreviewDockerOptions(provisionerName string, d drivers.Driver, engineOptions engine.Options) (*DockerOptions, error) {
+	registered, ok := provisioners[provisionerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown provisioner %q, expected one of: %s", provisionerName, strings.Join(provisionerNames(), ", "))
+	}
+
+	p := registered.New(d)
+	setter, ok := p.(optionsSetter)
+	if !ok {
+		return nil, fmt.Errorf("the %s provisioner does not support previewing its Docker options", provisionerName)
+	}
+
+	setter.SetEngineOptions(engineOptions)
+	setter.SetAuthOptions(setRemoteAuthOptions(p))
+
+	return p.GenerateDockerOptions(engine.DefaultPort)
+}
+
+func provisionerNames() []string {
+	names := []string{}
+	for name := range provisioners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
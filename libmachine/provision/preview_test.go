@@ -0,0 +1,28 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewDockerOptions(t *testing.T) {
+	dockerCfg, err := PreviewDockerOptions("RedHat", &fakedriver.Driver{}, engine.Options{
+		StorageDriver: "overlay",
+		Labels:        []string{"env=test"},
+		TLSVerify:     true,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service", dockerCfg.EngineOptionsPath)
+	assert.Contains(t, dockerCfg.EngineOptions, "--storage-driver overlay --tlsverify --tlscacert /etc/docker/ca.pem --tlscert /etc/docker/server.pem --tlskey /etc/docker/server-key.pem --label env=test --label provider=Driver ")
+}
+
+func TestPreviewDockerOptionsUnknownProvisioner(t *testing.T) {
+	_, err := PreviewDockerOptions("Atomic", &fakedriver.Driver{}, engine.Options{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown provisioner "Atomic", expected one of: `)
+}
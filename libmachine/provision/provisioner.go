@@ -2,6 +2,8 @@ package provision
 
 import (
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
@@ -13,8 +15,10 @@ import (
 )
 
 var (
-	provisioners          = make(map[string]*RegisteredProvisioner)
-	detector     Detector = &StandardDetector{}
+	provisioners               = make(map[string]*RegisteredProvisioner)
+	osReleaseAliases           = make(map[string]string)
+	osReleaseVariants          = make(map[string]string)
+	detector          Detector = &StandardDetector{}
 )
 
 type SSHCommander interface {
@@ -22,6 +26,13 @@ type SSHCommander interface {
 	SSHCommand(args string) (string, error)
 }
 
+// StdinSSHCommander is implemented by the SSHCommanders able to feed data to
+// the standard input of the commands they run, for the data that must not
+// show on the command line.
+type StdinSSHCommander interface {
+	SSHCommandWithStdin(args string, stdin io.Reader) (string, error)
+}
+
 type Detector interface {
 	DetectProvisioner(d drivers.Driver) (Provisioner, error)
 }
@@ -46,6 +57,13 @@ type Provisioner interface {
 	// Return the auth options used to configure remote connection for the daemon.
 	GetAuthOptions() auth.Options
 
+	// Return the engine options the daemon is configured with.
+	GetEngineOptions() engine.Options
+
+	// Write the daemon options to the host unless it already has them, and
+	// report whether they changed so that the caller decides on a restart.
+	EnsureDockerConfig(dockerOptions DockerOptions) (bool, error)
+
 	// Run a package action e.g. install
 	Package(name string, action pkgaction.PackageAction) error
 
@@ -78,6 +96,14 @@ type Provisioner interface {
 
 	// Get the OS Release info for the current provisioner
 	GetOsReleaseInfo() (*OsRelease, error)
+
+	// Return the version of Docker installed on the host, e.g. 1.10.3 or
+	// 1.11.0-rc2.
+	GetDockerVersion() (string, error)
+
+	// Run read-only commands on the host and return their output, to be
+	// attached to bug reports when provisioning fails.
+	CollectDiagnostics() (string, error)
 }
 
 // RegisteredProvisioner creates a new provisioner
@@ -89,6 +115,24 @@ func Register(name string, p *RegisteredProvisioner) {
 	provisioners[name] = p
 }
 
+// RegisterOsReleaseAlias makes hosts whose /etc/os-release reports one of
+// the aliases as ID be detected as if they reported osReleaseID.
+func RegisterOsReleaseAlias(osReleaseID string, aliases ...string) {
+	for _, alias := range aliases {
+		osReleaseAliases[alias] = osReleaseID
+	}
+}
+
+// RegisterOsReleaseVariant makes hosts whose /etc/os-release reports one of
+// the variants as VARIANT_ID be detected as if they reported osReleaseID,
+// whatever their ID. Only the part of VARIANT_ID before the first dot is
+// matched, so "coreos" also covers e.g. "coreos.host".
+func RegisterOsReleaseVariant(osReleaseID string, variants ...string) {
+	for _, variant := range variants {
+		osReleaseVariants[variant] = osReleaseID
+	}
+}
+
 func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 	return detector.DetectProvisioner(d)
 }
@@ -101,23 +145,58 @@ func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisione
 
 	log.Info("Detecting the provisioner...")
 
-	osReleaseOut, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
+	osReleaseInfo, err := fetchOsRelease(GenericSSHCommander{Driver: d})
 	if err != nil {
-		return nil, fmt.Errorf("Error getting SSH command: %s", err)
+		return nil, err
 	}
 
-	osReleaseInfo, err := NewOsRelease([]byte(osReleaseOut))
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing /etc/os-release file: %s", err)
+	return detectFromOsRelease(d, osReleaseInfo)
+}
+
+// candidateOsReleaseIDs returns the IDs a host is matched against, in order of
+// preference: the ID registered for its variant, its own ID, a registered alias
+// for it, then the IDs in ID_LIKE.
+func candidateOsReleaseIDs(osReleaseInfo *OsRelease) []string {
+	ids := []string{}
+	variant := strings.SplitN(osReleaseInfo.VariantID, ".", 2)[0]
+	if id, ok := osReleaseVariants[variant]; ok {
+		ids = append(ids, id)
+	}
+	ids = append(ids, osReleaseInfo.ID)
+	if alias, ok := osReleaseAliases[osReleaseInfo.ID]; ok {
+		ids = append(ids, alias)
+	}
+	ids = append(ids, strings.Fields(osReleaseInfo.IDLike)...)
+
+	seen := make(map[string]bool)
+	candidates := []string{}
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			candidates = append(candidates, id)
+		}
 	}
 
-	for _, p := range provisioners {
-		provisioner := p.New(d)
-		provisioner.SetOsReleaseInfo(osReleaseInfo)
+	return candidates
+}
 
-		if provisioner.CompatibleWithHost() {
-			log.Debugf("found compatible host: %s", osReleaseInfo.ID)
-			return provisioner, nil
+func detectFromOsRelease(d drivers.Driver, osReleaseInfo *OsRelease) (Provisioner, error) {
+	for _, id := range candidateOsReleaseIDs(osReleaseInfo) {
+		info := *osReleaseInfo
+		info.ID = id
+
+		for _, p := range provisioners {
+			provisioner := p.New(d)
+			provisioner.SetOsReleaseInfo(&info)
+
+			if provisioner.CompatibleWithHost() {
+				if id != osReleaseInfo.ID {
+					log.Debugf("treating %s host as %s", osReleaseInfo.ID, id)
+				}
+				log.Debugf("found compatible host: %s", id)
+				configureSudoCommand(provisioner, d)
+				return provisioner, nil
+			}
 		}
 	}
 
@@ -0,0 +1,110 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFromOsReleaseIDLike(t *testing.T) {
+	osReleaseInfo, err := NewOsRelease([]byte(`NAME="Red Hat Enterprise Linux CoreOS"
+ID="rhcos"
+ID_LIKE="coreos fedora"
+VERSION_ID="4.1"
+`))
+	assert.NoError(t, err)
+
+	provisioner, err := detectFromOsRelease(&fakedriver.Driver{}, osReleaseInfo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "coreOS", provisioner.String())
+}
+
+func TestDetectFromOsReleaseAlias(t *testing.T) {
+	RegisterOsReleaseAlias("fedora", "fedora-coreos")
+	defer delete(osReleaseAliases, "fedora-coreos")
+
+	osReleaseInfo, err := NewOsRelease([]byte(`ID=fedora-coreos
+VERSION_ID=30
+`))
+	assert.NoError(t, err)
+
+	provisioner, err := detectFromOsRelease(&fakedriver.Driver{}, osReleaseInfo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "fedora", provisioner.String())
+}
+
+func TestDetectFromOsReleaseVariant(t *testing.T) {
+	osReleaseInfo, err := NewOsRelease([]byte(`NAME="Fedora CoreOS"
+ID=fedora
+VERSION_ID=31
+VARIANT_ID=coreos
+`))
+	assert.NoError(t, err)
+
+	provisioner, err := detectFromOsRelease(&fakedriver.Driver{}, osReleaseInfo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "coreOS", provisioner.String())
+}
+
+func TestDetectFromOsReleaseUnregisteredVariant(t *testing.T) {
+	osReleaseInfo, err := NewOsRelease([]byte(`NAME="Fedora"
+ID=fedora
+VERSION_ID=29
+VARIANT_ID=atomic.host
+`))
+	assert.NoError(t, err)
+
+	provisioner, err := detectFromOsRelease(&fakedriver.Driver{}, osReleaseInfo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "fedora", provisioner.String())
+}
+
+func TestDetectFromOsReleaseUnknown(t *testing.T) {
+	osReleaseInfo, err := NewOsRelease([]byte(`ID=unknownlinux
+`))
+	assert.NoError(t, err)
+
+	_, err = detectFromOsRelease(&fakedriver.Driver{}, osReleaseInfo)
+
+	assert.Equal(t, ErrDetectionFailed, err)
+}
+
+// osReleaseSSHCommander prints osRelease for the os-release command.
+type osReleaseSSHCommander struct {
+	osRelease string
+	recordingSSHCommander
+}
+
+func (sshCmder *osReleaseSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.recordingSSHCommander.SSHCommand(args)
+	if args == osReleaseCommand {
+		return sshCmder.osRelease, nil
+	}
+	return "", nil
+}
+
+func TestGetOsReleaseInfoFetchedOnce(t *testing.T) {
+	p := NewRedHatProvisioner("centos", &fakedriver.Driver{})
+	sshCmder := &osReleaseSSHCommander{osRelease: "ID=centos\nVERSION_ID=7\n"}
+	p.SSHCommander = sshCmder
+
+	info, err := p.GetOsReleaseInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "centos", info.ID)
+
+	_, err = generateYumRepoList(p)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{osReleaseCommand}, sshCmder.commands)
+
+	p.SetOsReleaseInfo(nil)
+	_, err = p.GetOsReleaseInfo()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{osReleaseCommand, osReleaseCommand}, sshCmder.commands)
+}
@@ -59,7 +59,7 @@ func (provisioner *RancherProvisioner) String() string {
 func (provisioner *RancherProvisioner) Service(name string, action serviceaction.ServiceAction) error {
 	command := fmt.Sprintf("sudo system-docker %s %s", action.String(), name)
 
-	if _, err := provisioner.SSHCommand(command); err != nil {
+	if _, err := serviceCommand(provisioner, command, action); err != nil {
 		return err
 	}
 
@@ -93,6 +93,11 @@ func (provisioner *RancherProvisioner) Package(name string, action pkgaction.Pac
 }
 
 func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -101,11 +106,12 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, aut
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "overlay"
 	} else if provisioner.EngineOptions.StorageDriver != "overlay" {
-		return fmt.Errorf("Unsupported storage driver: %s", provisioner.EngineOptions.StorageDriver)
+		return fmt.Errorf("Unsupported storage driver: %s, expected one of: overlay", provisioner.EngineOptions.StorageDriver)
 	}
 
-	log.Debugf("Setting hostname %s", provisioner.Driver.GetMachineName())
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
@@ -119,13 +125,15 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.Options, aut
 	log.Debugf("Preparing certificates")
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	log.Debugf("Setting up certificates")
-	if err := ConfigureAuth(provisioner); err != nil {
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	log.Debugf("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
@@ -162,6 +170,8 @@ func (provisioner *RancherProvisioner) upgrade() error {
 		log.Infof("Upgrade succeeded, rebooting")
 		// ignore errors here because the SSH connection will close
 		provisioner.SSHCommand("sudo reboot")
+		// the host comes back on the upgraded release
+		provisioner.SetOsReleaseInfo(nil)
 
 		return nil
 	}
@@ -208,6 +218,9 @@ func (provisioner *RancherProvisioner) upgradeIso() error {
 		return err
 	}
 
+	// the host now runs the release of the new ISO
+	provisioner.SetOsReleaseInfo(nil)
+
 	return mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Running))
 }
 
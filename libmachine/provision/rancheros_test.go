@@ -0,0 +1,19 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRancherProvisionUnsupportedStorageDriver(t *testing.T) {
+	p := NewRancherProvisioner(&fakedriver.Driver{})
+
+	err := p.Provision(swarm.Options{}, auth.Options{}, engine.Options{StorageDriver: "aufs"})
+
+	assert.EqualError(t, err, "Unsupported storage driver: aufs, expected one of: overlay")
+}
@@ -28,17 +28,20 @@ enabled=1
 gpgkey=https://yum.dockerproject.org/gpg
 `
 	engineConfigTemplate = `[Unit]
-Description=Docker Application Container Engine
-After=network.target docker.socket
-Requires=docker.socket
+Description={{ .Description }}
+Documentation={{ .Documentation }}
+After={{ .After }}
+Requires={{ .Requires }}
 
 [Service]
-ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-MountFlags=slave
-LimitNOFILE=1048576
-LimitNPROC=1048576
-LimitCORE=infinity
-Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+` + systemdExecStartTemplate + `
+{{ if .MountFlags }}MountFlags={{ .MountFlags }}
+{{ end }}{{ range .Limits }}{{ .Directive }}={{ .Value }}
+{{ end }}` + systemdEnvironmentTemplate + `
+{{ range $directive, $value := .EngineOptions.SystemdServiceOverrides }}{{ $directive }}={{ $value }}
+{{ end }}
+[Install]
+WantedBy={{ .WantedBy }}
 `
 
 	majorVersionRE = regexp.MustCompile(`^(\d+)(\..*)?`)
@@ -73,6 +76,28 @@ func (provisioner *RedHatProvisioner) String() string {
 	return "redhat"
 }
 
+// SetSSHCommandRunner makes the provisioner run its commands over SSH through
+// runner, nil restoring the default tty allocation. It has no effect once the
+// commands are run by anything else than RedHatSSHCommander, possibly with
+// sudo replaced.
+func (provisioner *RedHatProvisioner) SetSSHCommandRunner(runner SSHCommandRunner) {
+	switch cmder := provisioner.SSHCommander.(type) {
+	case RedHatSSHCommander:
+		cmder.Runner = runner
+		provisioner.SSHCommander = cmder
+	case SudoSSHCommander:
+		if redHatCmder, ok := cmder.SSHCommander.(RedHatSSHCommander); ok {
+			redHatCmder.Runner = runner
+			cmder.SSHCommander = redHatCmder
+			provisioner.SSHCommander = cmder
+		}
+	}
+}
+
+func (provisioner *RedHatProvisioner) CATrustStore() (string, string) {
+	return "/etc/pki/ca-trust/source/anchors", "sudo update-ca-trust"
+}
+
 func (provisioner *RedHatProvisioner) SetHostname(hostname string) error {
 	// we have to have SetHostname here as well to use the RedHat provisioner
 	// SSHCommand to add the tty allocation
@@ -147,6 +172,11 @@ func (provisioner *RedHatProvisioner) dockerDaemonResponding() bool {
 }
 
 func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
@@ -159,7 +189,9 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 	provisioner.EngineOptions.StorageDriver = storageDriver
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
@@ -184,17 +216,29 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
-	if err := makeDockerOptionsDir(provisioner); err != nil {
+	if err := ensureStorageDriverModule(provisioner, provisioner.EngineOptions.StorageDriver); err != nil {
+		return err
+	}
+
+	detectSelinux(provisioner, &provisioner.EngineOptions)
+
+	if err := provisionStep("options-dir", func() error {
+		return makeDockerOptionsDir(provisioner)
+	}); err != nil {
 		return err
 	}
 
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	if err := ConfigureAuth(provisioner); err != nil {
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
@@ -202,35 +246,11 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 }
 
 func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
-	var (
-		engineCfg  bytes.Buffer
-		configPath = provisioner.DaemonOptionsFile
-	)
-
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
-
-	// systemd / redhat will not load options if they are on newlines
-	// instead, it just continues with a different set of options; yeah...
-	t, err := template.New("engineConfig").Parse(engineConfigTemplate)
-	if err != nil {
-		return nil, err
-	}
-
-	engineConfigContext := EngineConfigContext{
-		DockerPort:       dockerPort,
-		AuthOptions:      provisioner.AuthOptions,
-		EngineOptions:    provisioner.EngineOptions,
-		DockerOptionsDir: provisioner.DockerOptionsDir,
-	}
-
-	t.Execute(&engineCfg, engineConfigContext)
-
-	daemonOptsDir := configPath
-	return &DockerOptions{
-		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: daemonOptsDir,
-	}, nil
+	return provisioner.generateDockerOptions(dockerPort, systemdUnit{
+		template:     engineConfigTemplate,
+		defaultAfter: []string{"network.target"},
+		socketUnits:  []string{"docker.socket"},
+	})
 }
 
 func generateYumRepoList(provisioner Provisioner) (*bytes.Buffer, error) {
@@ -2,36 +2,106 @@ package provision
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/ssh"
 )
 
-type RedHatSSHCommander struct {
-	Driver drivers.Driver
+// SSHCommandRunner runs commands over the SSH client of a host, wrapping them
+// as the host needs, e.g. in machinectl shell.
+type SSHCommandRunner interface {
+	RunSSHCommand(client ssh.Client, args string) (string, error)
 }
 
-func (sshCmder RedHatSSHCommander) SSHCommand(args string) (string, error) {
-	client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
-	if err != nil {
-		return "", err
-	}
+// StdinSSHCommandRunner is implemented by the SSHCommandRunners able to feed
+// data to the standard input of the commands they run.
+type StdinSSHCommandRunner interface {
+	RunSSHCommandWithStdin(client ssh.Client, args string, stdin io.Reader) (string, error)
+}
 
-	log.Debugf("About to run SSH command:\n%s", args)
+// SSHCommandRunnerSetter is implemented by provisioners whose way of running
+// commands over SSH can be replaced.
+type SSHCommandRunnerSetter interface {
+	SetSSHCommandRunner(runner SSHCommandRunner)
+}
+
+// TTYSSHCommandRunner runs commands with a tty allocated, which sudo requires
+// on RedHat hosts.
+type TTYSSHCommandRunner struct{}
 
+func (runner TTYSSHCommandRunner) RunSSHCommand(client ssh.Client, args string) (string, error) {
 	// redhat needs "-t" for tty allocation on ssh therefore we check for the
 	// external client and add as needed.
 	// Note: CentOS 7.0 needs multiple "-tt" to force tty allocation when ssh has
 	// no local tty.
-	var output string
 	switch c := client.(type) {
 	case *ssh.ExternalClient:
 		c.BaseArgs = append(c.BaseArgs, "-tt")
-		output, err = c.Output(args)
+		return c.Output(args)
 	case *ssh.NativeClient:
-		output, err = c.OutputWithPty(args)
+		return c.OutputWithPty(args)
+	}
+
+	return client.Output(args)
+}
+
+func (runner TTYSSHCommandRunner) RunSSHCommandWithStdin(client ssh.Client, args string, stdin io.Reader) (string, error) {
+	switch c := client.(type) {
+	case *ssh.ExternalClient:
+		c.BaseArgs = append(c.BaseArgs, "-tt")
+		return c.OutputWithStdin(args, stdin)
+	case *ssh.NativeClient:
+		return c.OutputWithPtyAndStdin(args, stdin)
+	}
+
+	stdinClient, ok := client.(ssh.StdinClient)
+	if !ok {
+		return "", fmt.Errorf("the %T SSH client cannot feed the standard input of a command", client)
 	}
+	return stdinClient.OutputWithStdin(args, stdin)
+}
+
+// RedHatSSHCommander runs commands through Runner, a TTYSSHCommandRunner when
+// nil.
+type RedHatSSHCommander struct {
+	Driver drivers.Driver
+	Runner SSHCommandRunner
+}
+
+func (sshCmder RedHatSSHCommander) SSHCommand(args string) (string, error) {
+	return sshCmder.run(args, func(runner SSHCommandRunner, client ssh.Client) (string, error) {
+		return runner.RunSSHCommand(client, args)
+	})
+}
+
+// SSHCommandWithStdin runs args like SSHCommand, with stdin as its standard
+// input, which Runner must then be able to feed.
+func (sshCmder RedHatSSHCommander) SSHCommandWithStdin(args string, stdin io.Reader) (string, error) {
+	return sshCmder.run(args, func(runner SSHCommandRunner, client ssh.Client) (string, error) {
+		stdinRunner, ok := runner.(StdinSSHCommandRunner)
+		if !ok {
+			return "", fmt.Errorf("%T cannot feed the standard input of a command", runner)
+		}
+		return stdinRunner.RunSSHCommandWithStdin(client, args, stdin)
+	})
+}
+
+func (sshCmder RedHatSSHCommander) run(args string, run func(runner SSHCommandRunner, client ssh.Client) (string, error)) (string, error) {
+	client, err := drivers.GetSSHClientFromDriver(sshCmder.Driver)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("About to run SSH command:\n%s", args)
+
+	runner := sshCmder.Runner
+	if runner == nil {
+		runner = TTYSSHCommandRunner{}
+	}
+
+	output, err := run(runner, client)
 
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
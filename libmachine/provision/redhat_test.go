@@ -1,13 +1,17 @@
 package provision
 
 import (
+	"errors"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 )
 
@@ -41,3 +45,92 @@ func TestRedHatDefaultStorageDriver(t *testing.T) {
 		t.Fatal("Default storage driver should be devicemapper")
 	}
 }
+
+func TestRedHatGenerateDockerOptionsTemplateError(t *testing.T) {
+	defer func(tmpl string) { engineConfigTemplate = tmpl }(engineConfigTemplate)
+	engineConfigTemplate = "ExecStart=/usr/bin/docker daemon {{ .EngineOptions.NoSuchOption }}"
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	if err == nil {
+		t.Fatal("expected an error executing the engine config template")
+	}
+	if dockerCfg != nil {
+		t.Fatalf("expected no docker options, got %v", dockerCfg)
+	}
+}
+
+func TestRedHatGenerateDockerOptionsWantedBy(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(dockerCfg.EngineOptions, "\n[Install]\nWantedBy=multi-user.target\n") {
+		t.Fatalf("expected the unit to be wanted by multi-user.target, got %q", dockerCfg.EngineOptions)
+	}
+
+	p.EngineOptions = engine.Options{SystemdWantedBy: "network-online.target"}
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(dockerCfg.EngineOptions, "\n[Install]\nWantedBy=network-online.target\n") {
+		t.Fatalf("expected the unit to be wanted by network-online.target, got %q", dockerCfg.EngineOptions)
+	}
+}
+
+func TestRedHatProvisionResolvesStorageDriver(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SetDryRun(true)
+
+	if err := p.Provision(swarm.Options{}, authOptions, engine.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if storageDriver := p.GetEngineOptions().StorageDriver; storageDriver != "devicemapper" {
+		t.Fatalf("expected the devicemapper storage driver to be resolved, got %q", storageDriver)
+	}
+}
+
+// prefixingSSHCommandRunner records the commands it is given, prefixed, and
+// fails them like an unreachable host would.
+type prefixingSSHCommandRunner struct {
+	prefix   string
+	commands []string
+}
+
+func (runner *prefixingSSHCommandRunner) RunSSHCommand(client ssh.Client, args string) (string, error) {
+	runner.commands = append(runner.commands, runner.prefix+args)
+	return "", errors.New("connection refused")
+}
+
+func TestRedHatProvisionSSHCommandRunner(t *testing.T) {
+	runner := &prefixingSSHCommandRunner{prefix: "machinectl shell .host /bin/sh -c "}
+	p := NewRedHatProvisioner("", &fakedriver.Driver{})
+	p.SetSudoCommand("doas")
+	p.SetSSHCommandRunner(runner)
+
+	if err := p.Provision(swarm.Options{}, auth.Options{}, engine.Options{}); err == nil {
+		t.Fatal("expected the provisioning to fail")
+	}
+
+	if len(runner.commands) == 0 {
+		t.Fatal("expected the commands to be run through the custom runner")
+	}
+	if !strings.HasPrefix(runner.commands[0], "machinectl shell .host /bin/sh -c ") {
+		t.Fatalf("expected the command to be wrapped, got %q", runner.commands[0])
+	}
+
+	p.SetSSHCommandRunner(nil)
+	if cmder := p.SSHCommander.(SudoSSHCommander).SSHCommander.(RedHatSSHCommander); cmder.Runner != nil {
+		t.Fatalf("expected the default runner to be restored, got %v", cmder.Runner)
+	}
+}
@@ -0,0 +1,116 @@
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// sudoCommandRE matches sudo where it starts a command of a shell command
+// line, along with the -E flag some provisioners pass to it.
+var sudoCommandRE = regexp.MustCompile(`(^|[;&|(]|\bthen|\belse|\bdo)(\s*)sudo(\s+-E)?\s+`)
+
+// SudoCommandSetter is implemented by provisioners able to run their
+// commands with something other than sudo, or with no prefix at all.
+type SudoCommandSetter interface {
+	SetSudoCommand(sudo string)
+}
+
+// SudoPasswordSetter is implemented by provisioners able to run sudo on
+// hosts where it asks for the password of the SSH user.
+type SudoPasswordSetter interface {
+	SetSudoPassword(password string)
+}
+
+// SudoSSHCommander runs commands through the SSHCommander it wraps, after
+// replacing each sudo invocation with SudoCommand, or dropping it when
+// SudoCommand is empty. When sudo itself is used and SudoPassword is set, the
+// password is fed to "sudo -S" ahead of the commands needing it, over the
+// standard input of the session so that it is not part of the command line.
+type SudoSSHCommander struct {
+	SSHCommander SSHCommander
+	SudoCommand  string
+	SudoPassword string
+}
+
+func (cmder SudoSSHCommander) SSHCommand(args string) (string, error) {
+	if cmder.SudoCommand != "sudo" || cmder.SudoPassword == "" || !sudoCommandRE.MatchString(args) {
+		return cmder.SSHCommander.SSHCommand(replaceSudoCommand(args, cmder.SudoCommand))
+	}
+
+	stdinCmder, ok := cmder.SSHCommander.(StdinSSHCommander)
+	if !ok {
+		return "", fmt.Errorf("%T cannot send the sudo password over the standard input of the session", cmder.SSHCommander)
+	}
+
+	// sudo -v caches the credentials for the sudo invocations that follow,
+	// which get no standard input, sudo leaving the password unread when the
+	// credentials are already cached
+	output, err := stdinCmder.SSHCommandWithStdin(
+		fmt.Sprintf("sudo -S -p '' -v && exec </dev/null && %s", args),
+		strings.NewReader(cmder.SudoPassword+"\n"),
+	)
+	if err != nil {
+		// a tty may echo the password in the output part of the error
+		return output, errors.New(strings.Replace(err.Error(), cmder.SudoPassword, "<REDACTED>", -1))
+	}
+
+	return output, nil
+}
+
+func replaceSudoCommand(command, sudo string) string {
+	replacement := "${1}${2}"
+	if sudo != "" {
+		replacement += sudo + "${3} "
+	}
+
+	return sudoCommandRE.ReplaceAllString(command, replacement)
+}
+
+// SetSudoCommand makes the provisioner run its privileged commands with sudo
+// replaced by the given command; an empty one runs them as is, which is what
+// a root SSH user without sudo installed needs.
+func (provisioner *GenericProvisioner) SetSudoCommand(sudo string) {
+	cmder := provisioner.unwrapSudoSSHCommander()
+	cmder.SudoCommand = sudo
+	provisioner.wrapSudoSSHCommander(cmder)
+}
+
+// SetSudoPassword makes the provisioner give password to sudo when running
+// its privileged commands, an empty one running sudo without a password.
+// The password is kept out of the logs.
+func (provisioner *GenericProvisioner) SetSudoPassword(password string) {
+	log.RegisterSecret(password)
+
+	cmder := provisioner.unwrapSudoSSHCommander()
+	cmder.SudoPassword = password
+	provisioner.wrapSudoSSHCommander(cmder)
+}
+
+func (provisioner *GenericProvisioner) unwrapSudoSSHCommander() SudoSSHCommander {
+	cmder, ok := provisioner.SSHCommander.(SudoSSHCommander)
+	if !ok {
+		return SudoSSHCommander{SSHCommander: provisioner.SSHCommander, SudoCommand: "sudo"}
+	}
+
+	provisioner.SSHCommander = cmder.SSHCommander
+	return cmder
+}
+
+func (provisioner *GenericProvisioner) wrapSudoSSHCommander(cmder SudoSSHCommander) {
+	if cmder.SudoCommand != "sudo" || cmder.SudoPassword != "" {
+		provisioner.SSHCommander = cmder
+	}
+}
+
+// configureSudoCommand drops sudo from the provisioner's commands when the
+// driver logs in as root, as minimal images may not ship sudo at all.
+func configureSudoCommand(p Provisioner, d drivers.Driver) {
+	if setter, ok := p.(SudoCommandSetter); ok && d.GetSSHUsername() == "root" {
+		setter.SetSudoCommand("")
+	}
+}
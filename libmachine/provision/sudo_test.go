@@ -0,0 +1,151 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceSudoCommand(t *testing.T) {
+	cases := []struct {
+		command  string
+		sudo     string
+		expected string
+	}{
+		{"sudo systemctl -f restart docker", "", "systemctl -f restart docker"},
+		{"sudo -E yum install -y curl", "", "yum install -y curl"},
+		{"sudo -E yum install -y curl", "sudo", "sudo -E yum install -y curl"},
+		{"sudo hostname foo && echo \"foo\" | sudo tee /etc/hostname", "", "hostname foo && echo \"foo\" | tee /etc/hostname"},
+		{"if [ ! -z \"$(ip link show docker0)\" ]; then sudo ip link delete docker0; fi", "", "if [ ! -z \"$(ip link show docker0)\" ]; then ip link delete docker0; fi"},
+		{"if ! type sudo; then apt-get install -y sudo; fi", "", "if ! type sudo; then apt-get install -y sudo; fi"},
+		{"sudo systemctl daemon-reload", "doas", "doas systemctl daemon-reload"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, replaceSudoCommand(c.command, c.sudo))
+	}
+}
+
+func TestSetSudoCommandEmpty(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	p.SetSudoCommand("")
+
+	assert.NoError(t, p.Service("docker", serviceaction.Restart))
+	assert.NoError(t, p.Package("curl", pkgaction.Install))
+	assert.Equal(t, []string{
+		"systemctl daemon-reload",
+		"systemctl -f restart docker",
+		"yum install -y curl",
+	}, sshCmder.commands)
+
+	p.SetSudoCommand("sudo")
+	assert.Equal(t, sshCmder, p.SSHCommander)
+}
+
+type rootDriver struct {
+	*fakedriver.Driver
+}
+
+func (d rootDriver) GetSSHUsername() string {
+	return "root"
+}
+
+func TestConfigureSudoCommand(t *testing.T) {
+	for _, d := range []drivers.Driver{&fakedriver.Driver{}, rootDriver{&fakedriver.Driver{}}} {
+		p := NewRedHatProvisioner("rhel", d)
+		sshCmder := &recordingSSHCommander{}
+		p.SSHCommander = sshCmder
+
+		configureSudoCommand(p, d)
+		_, err := p.SSHCommand("sudo systemctl daemon-reload")
+
+		assert.NoError(t, err)
+		if d.GetSSHUsername() == "root" {
+			assert.Equal(t, []string{"systemctl daemon-reload"}, sshCmder.commands)
+		} else {
+			assert.Equal(t, []string{"sudo systemctl daemon-reload"}, sshCmder.commands)
+		}
+	}
+}
+
+// loggingSSHCommander logs and fails its commands like the driver SSH
+// client does, recording what they are fed on their standard input.
+type loggingSSHCommander struct {
+	recordingSSHCommander
+	stdins []string
+}
+
+func (sshCmder *loggingSSHCommander) SSHCommand(args string) (string, error) {
+	log.Debugf("About to run SSH command:\n%s", args)
+	sshCmder.recordingSSHCommander.SSHCommand(args)
+	return "", fmt.Errorf("Something went wrong running an SSH command!\ncommand : %s", args)
+}
+
+func (sshCmder *loggingSSHCommander) SSHCommandWithStdin(args string, stdin io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	sshCmder.stdins = append(sshCmder.stdins, string(data))
+	return sshCmder.SSHCommand(args)
+}
+
+func TestSetSudoPassword(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetDebug(true)
+	log.SetErrWriter(&logOutput)
+	defer func() {
+		log.SetDebug(false)
+		log.SetErrWriter(os.Stderr)
+	}()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	sshCmder := &loggingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	p.SetSudoPassword("s3cr'et")
+	_, err := p.SSHCommand("sudo systemctl daemon-reload")
+
+	assert.Equal(t, []string{"sudo -S -p '' -v && exec </dev/null && sudo systemctl daemon-reload"}, sshCmder.commands)
+	assert.Equal(t, []string{"s3cr'et\n"}, sshCmder.stdins)
+	assert.Contains(t, logOutput.String(), "sudo -S -p '' -v && exec </dev/null && sudo systemctl daemon-reload")
+	assert.NotContains(t, logOutput.String(), "s3cr")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr")
+	assert.NotContains(t, strings.Join(log.History(), "\n"), "s3cr")
+
+	_, err = p.SSHCommand("hostname")
+
+	assert.Error(t, err)
+	assert.Equal(t, "hostname", sshCmder.commands[1])
+	assert.Len(t, sshCmder.stdins, 1)
+
+	p.SetSudoPassword("")
+	assert.Equal(t, sshCmder, p.SSHCommander)
+}
+
+func TestSetSudoPasswordWithoutStdin(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	p.SetSudoPassword("s3cret")
+	_, err := p.SSHCommand("sudo systemctl daemon-reload")
+
+	assert.Error(t, err)
+	assert.Empty(t, sshCmder.commands)
+}
@@ -80,6 +80,10 @@ func (provisioner *SUSEProvisioner) String() string {
 	return "suse"
 }
 
+func (provisioner *SUSEProvisioner) CATrustStore() (string, string) {
+	return "/etc/pki/trust/anchors", "sudo update-ca-certificates"
+}
+
 func (provisioner *SUSEProvisioner) Service(name string, action serviceaction.ServiceAction) error {
 	reloadDaemon := false
 	switch action {
@@ -91,14 +95,14 @@ func (provisioner *SUSEProvisioner) Service(name string, action serviceaction.Se
 	// be sure exactly when it changes from the provisioner so
 	// we call a reload on every restart to be safe
 	if reloadDaemon {
-		if _, err := provisioner.SSHCommand("sudo systemctl daemon-reload"); err != nil {
+		if _, err := sshCommandWithRetry(provisioner, "sudo systemctl daemon-reload"); err != nil {
 			return err
 		}
 	}
 
 	command := fmt.Sprintf("sudo systemctl %s %s", action.String(), name)
 
-	if _, err := provisioner.SSHCommand(command); err != nil {
+	if _, err := sshCommandWithRetry(provisioner, command); err != nil {
 		return err
 	}
 
@@ -140,12 +144,19 @@ func (provisioner *SUSEProvisioner) dockerDaemonResponding() bool {
 }
 
 func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	engineOptions, err := resolveEngineConfigURL(engineOptions)
+	if err != nil {
+		return err
+	}
+
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 	swarmOptions.Env = engineOptions.Env
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisionStep("hostname", func() error {
+		return setMachineHostname(provisioner)
+	}); err != nil {
 		return err
 	}
 
@@ -184,17 +195,23 @@ func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.Options, authOp
 		return err
 	}
 
-	if err := makeDockerOptionsDir(provisioner); err != nil {
+	if err := provisionStep("options-dir", func() error {
+		return makeDockerOptionsDir(provisioner)
+	}); err != nil {
 		return err
 	}
 
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
-	if err := ConfigureAuth(provisioner); err != nil {
+	if err := provisionStep("certs", func() error {
+		return ConfigureAuth(provisioner)
+	}); err != nil {
 		return err
 	}
 
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisionStep("swarm", func() error {
+		return configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions)
+	}); err != nil {
 		return err
 	}
 
@@ -207,13 +224,16 @@ func (provisioner *SUSEProvisioner) GenerateDockerOptions(dockerPort int) (*Dock
 		configPath = provisioner.DaemonOptionsFile
 	)
 
+	if err := validateDockerPort(dockerPort); err != nil {
+		return nil, err
+	}
+
 	// remove existing
 	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo rm %s", configPath)); err != nil {
 		return nil, err
 	}
 
-	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
-	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Labels = appendDefaultLabels(provisioner.EngineOptions, provisioner.Driver)
 
 	engineConfigTmpl := `# File automatically generated by docker-machine
 DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} {{ if .EngineOptions.StorageDriver }} --storage-driver {{.EngineOptions.StorageDriver}} {{ end }} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}'
@@ -230,7 +250,9 @@ DOCKER_OPTS=' -H tcp://0.0.0.0:{{.DockerPort}} {{ if .EngineOptions.StorageDrive
 		DockerOptionsDir: provisioner.DockerOptionsDir,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
 	daemonOptsDir := configPath
 	return &DockerOptions{
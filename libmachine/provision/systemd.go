@@ -2,15 +2,153 @@ package provision
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 	"text/template"
 
 	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
 )
 
+const (
+	// systemdExecStartTemplate is the daemon command line shared by the
+	// docker.service unit and drop-in templates.
+	// When the daemon is configured through daemon.json, only the arbitrary
+	// flags remain on its command line.
+	systemdExecStartTemplate = `ExecStart={{.DaemonCommand}}{{ if .EngineOptions.UseDaemonJSON }}{{ range .EngineOptions.ArbitraryFlags }} --{{.}}{{ end }}{{ else }} {{ if .SocketActivation }}-H fd://{{ else }}-H tcp://{{.ListenAddress}}:{{.DockerPort}} -H unix:///var/run/docker.sock{{ if .EngineOptions.SocketGroup }} --group {{ .EngineOptions.SocketGroup }}{{ end }}{{ end }}{{ range .EngineOptions.ExtraHosts }} -H {{.}}{{ end }}{{ if .EngineOptions.StorageDriver }} --storage-driver {{.EngineOptions.StorageDriver}}{{ end }}{{ range .EngineOptions.StorageOpts }} --storage-opt {{.}}{{ end }}{{ if .DataRootFlag }} --{{ .DataRootFlag }} {{ .EngineOptions.DataRoot }}{{ end }}{{ if .EngineOptions.SelinuxEnabled }} --selinux-enabled{{ end }}{{ if not .EngineOptions.DisableTLSVerify }} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}}{{ end }} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range $name, $path := .EngineOptions.Runtimes }}--add-runtime {{ $name }}={{ $path }} {{ end }}{{ if .EngineOptions.DefaultRuntime }}--default-runtime {{ .EngineOptions.DefaultRuntime }} {{ end }}{{ if .EngineOptions.LogDriver }}--log-driver {{ .EngineOptions.LogDriver }} {{ end }}{{ range $key, $value := .EngineOptions.LogOpts }}--log-opt {{ $key }}={{ $value }} {{ end }}{{ if .EngineOptions.CgroupDriver }}--exec-opt native.cgroupdriver={{ .EngineOptions.CgroupDriver }} {{ end }}{{ if .EngineOptions.LiveRestore }}--live-restore {{ end }}{{ if .EngineOptions.UsernsRemap }}--userns-remap={{ .EngineOptions.UsernsRemap }} {{ end }}{{ if .EngineOptions.BridgeIP }}--bip {{ .EngineOptions.BridgeIP }} {{ end }}{{ range .EngineOptions.DefaultAddressPools }}--default-address-pool {{.}} {{ end }}{{ range $name, $limits := .EngineOptions.DefaultUlimits }}--default-ulimit {{ $name }}={{ $limits }} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}{{ end }}`
+
+	// systemdEnvironmentTemplate sets the daemon environment inline, or
+	// points at systemdEnvFile when it is written there. The file is only
+	// written when there is an environment, hence optional.
+	systemdEnvironmentTemplate = `{{ if .EngineOptions.UseEnvFile }}EnvironmentFile=-` + systemdEnvFile + `{{ else }}Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}{{ end }}`
+
+	systemdEnvFile = "/etc/sysconfig/docker-machine"
+
+	// systemdEngineConfigTemplate is the docker.service unit replacing the
+	// distribution's.
+	systemdEngineConfigTemplate = `[Unit]
+Description={{ .Description }}
+Documentation={{ .Documentation }}
+{{ if .After }}After={{ .After }}
+{{ end }}{{ if .Requires }}Requires={{ .Requires }}
+{{ end }}
+[Service]
+` + systemdExecStartTemplate + `
+{{ if .MountFlags }}MountFlags={{ .MountFlags }}
+{{ end }}{{ range .Limits }}{{ .Directive }}={{ .Value }}
+{{ end }}` + systemdEnvironmentTemplate + `
+{{ range $directive, $value := .EngineOptions.SystemdServiceOverrides }}{{ $directive }}={{ $value }}
+{{ end }}
+[Install]
+WantedBy={{ .WantedBy }}
+`
+
+	// systemdDropInTemplate only overrides the daemon command and its
+	// environment, leaving the rest of the distribution's unit untouched
+	// unless its description, documentation, dependencies, mount flags or
+	// limits are explicitly configured.
+	systemdDropInTemplate = `{{ if or .EngineOptions.UnitDescription .EngineOptions.UnitDocumentation .EngineOptions.UnitAfter .EngineOptions.UnitRequires }}[Unit]
+{{ if .EngineOptions.UnitDescription }}Description={{ .EngineOptions.UnitDescription }}
+{{ end }}{{ if .EngineOptions.UnitDocumentation }}Documentation=
+Documentation={{ .EngineOptions.UnitDocumentation }}
+{{ end }}{{ if .EngineOptions.UnitAfter }}After={{ .After }}
+{{ end }}{{ if .EngineOptions.UnitRequires }}Requires={{ .Requires }}
+{{ end }}
+{{ end }}[Service]
+ExecStart=
+` + systemdExecStartTemplate + `
+{{ if .EngineOptions.MountFlags }}MountFlags={{ .MountFlags }}
+{{ end }}{{ range .Limits }}{{ if .Configured }}{{ .Directive }}={{ .Value }}
+{{ end }}{{ end }}` + systemdEnvironmentTemplate + `
+{{ range $directive, $value := .EngineOptions.SystemdServiceOverrides }}{{ $directive }}={{ $value }}
+{{ end }}`
+
+	systemdDropInFile = "/etc/systemd/system/docker.service.d/10-machine.conf"
+
+	// systemdSocketTemplate is the docker.socket unit passing the TCP and
+	// unix sockets to a socket activated daemon.
+	systemdSocketTemplate = `[Unit]
+Description=Docker Socket for the API
+PartOf=docker.service
+
+[Socket]
+ListenStream={{.ListenAddress}}:{{.DockerPort}}
+ListenStream=/var/run/docker.sock
+SocketMode=0660
+SocketUser=root
+SocketGroup={{ if .EngineOptions.SocketGroup }}{{ .EngineOptions.SocketGroup }}{{ else }}docker{{ end }}
+
+[Install]
+WantedBy=sockets.target
+`
+
+	systemdSocketFile = "/etc/systemd/system/docker.socket"
+
+	daemonJSONFile = "daemon.json"
+)
+
+// daemonJSON holds the settings of the daemon.json written in place of the
+// daemon's command line flags.
+type daemonJSON struct {
+	Hosts              []string `json:"hosts"`
+	StorageDriver      string   `json:"storage-driver,omitempty"`
+	StorageOpts        []string `json:"storage-opts,omitempty"`
+	DataRoot           string   `json:"data-root,omitempty"`
+	Graph              string   `json:"graph,omitempty"`
+	Labels             []string `json:"labels,omitempty"`
+	InsecureRegistries []string `json:"insecure-registries,omitempty"`
+	RegistryMirrors    []string `json:"registry-mirrors,omitempty"`
+	SelinuxEnabled     bool     `json:"selinux-enabled,omitempty"`
+	TLSVerify          bool     `json:"tlsverify,omitempty"`
+	TLSCACert          string   `json:"tlscacert,omitempty"`
+	TLSCert            string   `json:"tlscert,omitempty"`
+	TLSKey             string   `json:"tlskey,omitempty"`
+
+	Runtimes       map[string]daemonRuntime `json:"runtimes,omitempty"`
+	DefaultRuntime string                   `json:"default-runtime,omitempty"`
+
+	LogDriver string            `json:"log-driver,omitempty"`
+	LogOpts   map[string]string `json:"log-opts,omitempty"`
+
+	ExecOpts []string `json:"exec-opts,omitempty"`
+	Group    string   `json:"group,omitempty"`
+
+	LiveRestore bool   `json:"live-restore,omitempty"`
+	UsernsRemap string `json:"userns-remap,omitempty"`
+
+	Bip                 string              `json:"bip,omitempty"`
+	DefaultAddressPools []daemonAddressPool `json:"default-address-pools,omitempty"`
+
+	DefaultUlimits map[string]daemonUlimit `json:"default-ulimits,omitempty"`
+}
+
+// daemonRuntime is an OCI runtime of the daemon.json.
+type daemonRuntime struct {
+	Path string `json:"path"`
+}
+
 type SystemdProvisioner struct {
 	GenericProvisioner
+	// UseDropIn makes GenerateDockerOptions write a drop-in for the
+	// distribution's docker.service instead of replacing the whole unit.
+	UseDropIn bool
+	// SocketActivation makes the daemon get its sockets from a generated
+	// docker.socket unit rather than binding them itself.
+	SocketActivation bool
+	// DockerVersion is the version of Docker installed on the host, which
+	// the unit is rendered for. EngineOptions.Version is used when empty.
+	DockerVersion string
+}
+
+func (p *SystemdProvisioner) socketActivated() bool {
+	return p.SocketActivation
+}
+
+func (p *SystemdProvisioner) SetDockerVersion(version string) {
+	p.DockerVersion = version
 }
 
 func (p *SystemdProvisioner) String() string {
@@ -19,7 +157,7 @@ func (p *SystemdProvisioner) String() string {
 
 func NewSystemdProvisioner(osReleaseID string, d drivers.Driver) SystemdProvisioner {
 	return SystemdProvisioner{
-		GenericProvisioner{
+		GenericProvisioner: GenericProvisioner{
 			SSHCommander:      GenericSSHCommander{Driver: d},
 			DockerOptionsDir:  "/etc/docker",
 			DaemonOptionsFile: "/etc/systemd/system/docker.service",
@@ -33,41 +171,279 @@ func NewSystemdProvisioner(osReleaseID string, d drivers.Driver) SystemdProvisio
 }
 
 func (p *SystemdProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var socketUnits []string
+	if p.SocketActivation {
+		socketUnits = []string{"docker.socket"}
+	}
+
+	return p.generateDockerOptions(dockerPort, systemdUnit{
+		template:    systemdEngineConfigTemplate,
+		socketUnits: socketUnits,
+	})
+}
+
+// systemdUnit describes the docker.service unit a systemd provisioner writes
+// when it replaces the distribution's.
+type systemdUnit struct {
+	template string
+	// defaultAfter and socketUnits are the units the daemon is ordered
+	// after by default, and those it always requires.
+	defaultAfter []string
+	socketUnits  []string
+}
+
+// generateDockerOptions validates the engine options, then renders the unit,
+// or the drop-in when UseDropIn is set, along with the other files holding
+// the daemon options.
+func (p *SystemdProvisioner) generateDockerOptions(dockerPort int, unit systemdUnit) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
 	)
 
-	driverNameLabel := fmt.Sprintf("provider=%s", p.Driver.DriverName())
-	p.EngineOptions.Labels = append(p.EngineOptions.Labels, driverNameLabel)
+	if err := validateEngineOptions(p.EngineOptions, dockerPort); err != nil {
+		return nil, err
+	}
 
-	engineConfigTmpl := `[Service]
-ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-MountFlags=slave
-LimitNOFILE=1048576
-LimitNPROC=1048576
-LimitCORE=infinity
-Environment={{range .EngineOptions.Env}}{{ printf "%q" . }} {{end}}
+	version := renderedDockerVersion(p.DockerVersion, p.EngineOptions)
 
-[Install]
-WantedBy=multi-user.target
-`
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	daemonCommand, err := engineDaemonCommand(version, p.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := engineUnitLimits(p.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	wantedBy, err := engineWantedBy(p.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	mountFlags, err := engineMountFlags(p.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	dataRootFlag, err := engineDataRootFlag(version, p.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	after, requires := engineUnitDependencies(p.EngineOptions, unit.defaultAfter, unit.socketUnits...)
+
+	p.EngineOptions.Labels = appendDefaultLabels(p.EngineOptions, p.Driver)
+
+	// systemd / redhat will not load options if they are on newlines
+	// instead, it just continues with a different set of options; yeah...
+	configTmpl, configPath := unit.template, p.DaemonOptionsFile
+	if p.UseDropIn {
+		configTmpl, configPath = systemdDropInTemplate, systemdDropInFile
+	}
+
+	t, err := template.New("engineConfig").Parse(configTmpl)
 	if err != nil {
 		return nil, err
 	}
 
 	engineConfigContext := EngineConfigContext{
-		DockerPort:    dockerPort,
-		AuthOptions:   p.AuthOptions,
-		EngineOptions: p.EngineOptions,
+		DockerPort:       dockerPort,
+		ListenAddress:    engineListenAddress(p.EngineOptions),
+		MountFlags:       mountFlags,
+		DaemonCommand:    daemonCommand,
+		DataRootFlag:     dataRootFlag,
+		SocketActivation: p.SocketActivation,
+		Limits:           limits,
+		Description:      engineUnitDescription(p.EngineOptions),
+		Documentation:    engineUnitDocumentation(p.EngineOptions),
+		After:            after,
+		Requires:         requires,
+		WantedBy:         wantedBy,
+		AuthOptions:      p.AuthOptions,
+		EngineOptions:    p.EngineOptions,
+		DockerOptionsDir: p.DockerOptionsDir,
 	}
 
-	t.Execute(&engineCfg, engineConfigContext)
+	if err := t.Execute(&engineCfg, engineConfigContext); err != nil {
+		return nil, err
+	}
 
-	return &DockerOptions{
+	dockerOptions := &DockerOptions{
 		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: p.DaemonOptionsFile,
-	}, nil
+		EngineOptionsPath: configPath,
+		StaleFiles:        []StaleFile{staleUnitFile(p.UseDropIn, p.DaemonOptionsFile)},
+	}
+
+	if err := addSystemdSocket(dockerOptions, engineConfigContext); err != nil {
+		return nil, err
+	}
+
+	if err := addDaemonJSON(dockerOptions, engineConfigContext, p.DockerOptionsDir); err != nil {
+		return nil, err
+	}
+
+	addEnvFile(dockerOptions, p.EngineOptions)
+
+	return dockerOptions, nil
+}
+
+// staleUnitFile returns whichever of the full unit at unitPath and the drop-in
+// is not written, as the drop-in would keep overriding the ExecStart of a new
+// full unit, and a former full unit would keep replacing the distribution's.
+func staleUnitFile(useDropIn bool, unitPath string) StaleFile {
+	if useDropIn {
+		return StaleFile{Path: unitPath}
+	}
+
+	return StaleFile{Path: systemdDropInFile}
+}
+
+// addSystemdSocket renders the docker.socket unit into dockerOptions when the
+// daemon is socket activated.
+func addSystemdSocket(dockerOptions *DockerOptions, engineConfigContext EngineConfigContext) error {
+	if !engineConfigContext.SocketActivation {
+		return nil
+	}
+
+	t, err := template.New("socketConfig").Parse(systemdSocketTemplate)
+	if err != nil {
+		return err
+	}
+
+	var socketCfg bytes.Buffer
+	if err := t.Execute(&socketCfg, engineConfigContext); err != nil {
+		return err
+	}
+
+	dockerOptions.SocketOptions = socketCfg.String()
+	dockerOptions.SocketOptionsPath = systemdSocketFile
+	return nil
+}
+
+// addEnvFile renders the environment file into dockerOptions when the daemon
+// environment is not set inline in its unit.
+func addEnvFile(dockerOptions *DockerOptions, engineOptions engine.Options) {
+	if !engineOptions.UseEnvFile {
+		return
+	}
+
+	var envFile bytes.Buffer
+	for _, env := range engineOptions.Env {
+		if parts := strings.SplitN(env, "=", 2); len(parts) == 2 {
+			fmt.Fprintf(&envFile, "%s=%q\n", parts[0], parts[1])
+		} else {
+			fmt.Fprintf(&envFile, "%s\n", env)
+		}
+	}
+
+	dockerOptions.EnvFile = envFile.String()
+	dockerOptions.EnvFilePath = systemdEnvFile
+}
+
+// addDaemonJSON renders the daemon.json into dockerOptions when the daemon is
+// configured through it rather than through command line flags, and marks the
+// one machine wrote before as stale otherwise.
+func addDaemonJSON(dockerOptions *DockerOptions, engineConfigContext EngineConfigContext, dockerOptionsDir string) error {
+	engineOptions := engineConfigContext.EngineOptions
+	if !engineOptions.UseDaemonJSON {
+		if dockerOptionsDir == "" {
+			return nil
+		}
+		// The daemon.json machine writes always sets the hosts, which the
+		// -H flags of the unit would conflict with.
+		dockerOptions.StaleFiles = append(dockerOptions.StaleFiles, StaleFile{
+			Path:   path.Join(dockerOptionsDir, daemonJSONFile),
+			Marker: `"hosts"`,
+		})
+		return nil
+	}
+
+	config := daemonJSON{
+		StorageDriver:      engineOptions.StorageDriver,
+		StorageOpts:        engineOptions.StorageOpts,
+		Labels:             engineOptions.Labels,
+		InsecureRegistries: engineOptions.InsecureRegistry,
+		RegistryMirrors:    engineOptions.RegistryMirror,
+		SelinuxEnabled:     engineOptions.SelinuxEnabled,
+		DefaultRuntime:     engineOptions.DefaultRuntime,
+		LogDriver:          engineOptions.LogDriver,
+		LogOpts:            engineOptions.LogOpts,
+		LiveRestore:        engineOptions.LiveRestore,
+		UsernsRemap:        engineOptions.UsernsRemap,
+		Bip:                engineOptions.BridgeIP,
+	}
+
+	for _, pool := range engineOptions.DefaultAddressPools {
+		addressPool, err := parseDefaultAddressPool(pool)
+		if err != nil {
+			return err
+		}
+		config.DefaultAddressPools = append(config.DefaultAddressPools, addressPool)
+	}
+
+	for name, limits := range engineOptions.DefaultUlimits {
+		ulimit, err := parseDefaultUlimit(name, limits)
+		if err != nil {
+			return err
+		}
+		if config.DefaultUlimits == nil {
+			config.DefaultUlimits = map[string]daemonUlimit{}
+		}
+		config.DefaultUlimits[name] = ulimit
+	}
+
+	if engineOptions.CgroupDriver != "" {
+		config.ExecOpts = []string{"native.cgroupdriver=" + engineOptions.CgroupDriver}
+	}
+
+	switch engineConfigContext.DataRootFlag {
+	case "data-root":
+		config.DataRoot = engineOptions.DataRoot
+	case "graph":
+		config.Graph = engineOptions.DataRoot
+	}
+
+	for name, runtimePath := range engineOptions.Runtimes {
+		if config.Runtimes == nil {
+			config.Runtimes = map[string]daemonRuntime{}
+		}
+		config.Runtimes[name] = daemonRuntime{Path: runtimePath}
+	}
+
+	if engineConfigContext.SocketActivation {
+		config.Hosts = []string{"fd://"}
+	} else {
+		config.Hosts = []string{
+			fmt.Sprintf("tcp://%s:%d", engineConfigContext.ListenAddress, engineConfigContext.DockerPort),
+			"unix:///var/run/docker.sock",
+		}
+		config.Group = engineOptions.SocketGroup
+	}
+	config.Hosts = append(config.Hosts, engineOptions.ExtraHosts...)
+
+	if !engineOptions.DisableTLSVerify {
+		config.TLSVerify = true
+		config.TLSCACert = engineConfigContext.AuthOptions.CaCertRemotePath
+		config.TLSCert = engineConfigContext.AuthOptions.ServerCertRemotePath
+		config.TLSKey = engineConfigContext.AuthOptions.ServerKeyRemotePath
+	}
+
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	dockerOptions.DaemonJSON = string(data)
+	dockerOptions.DaemonJSONPath = path.Join(dockerOptionsDir, daemonJSONFile)
+	return nil
+}
+
+func (p *SystemdProvisioner) CollectDiagnostics() (string, error) {
+	return collectDiagnostics(p, append([]string{
+		"sudo systemctl status docker --no-pager",
+		"sudo journalctl -u docker --no-pager -n 200",
+	}, genericDiagnosticsCommands...))
 }
 
 func (p *SystemdProvisioner) Service(name string, action serviceaction.ServiceAction) error {
@@ -81,14 +457,14 @@ func (p *SystemdProvisioner) Service(name string, action serviceaction.ServiceAc
 	// be sure exactly when it changes from the provisioner so
 	// we call a reload on every restart to be safe
 	if reloadDaemon {
-		if _, err := p.SSHCommand("sudo systemctl daemon-reload"); err != nil {
+		if _, err := sshCommandWithRetry(p, "sudo systemctl daemon-reload"); err != nil {
 			return err
 		}
 	}
 
 	command := fmt.Sprintf("sudo systemctl -f %s %s", action.String(), name)
 
-	if _, err := p.SSHCommand(command); err != nil {
+	if _, err := sshCommandWithRetry(p, command); err != nil {
 		return err
 	}
 
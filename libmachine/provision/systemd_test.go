@@ -0,0 +1,1129 @@
+package provision
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/provisiontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemdGenerateDockerOptionsServiceOverrides(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		SystemdServiceOverrides: map[string]string{
+			"TasksMax": "infinity",
+		},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(dockerCfg.EngineOptions, "\nTasksMax=infinity\n"))
+}
+
+func TestGenerateDockerOptionsInvalidServiceOverrides(t *testing.T) {
+	for _, c := range []struct {
+		overrides map[string]string
+		err       string
+	}{
+		{map[string]string{"ExecStart": "/usr/bin/dockerd"}, `systemd service override "ExecStart" conflicts with the ExecStart directive set by the provisioner`},
+		{map[string]string{"EnvironmentFile": "/etc/default/docker"}, `systemd service override "EnvironmentFile" conflicts with the EnvironmentFile directive set by the provisioner`},
+		{map[string]string{"[Install]": ""}, `invalid systemd service override "[Install]": section headers cannot be added`},
+		{map[string]string{"TasksMax=infinity\nExecStartPre": "/bin/true"}, `invalid systemd service override "TasksMax=infinity\nExecStartPre": not a directive name`},
+		{map[string]string{"TasksMax": "infinity\nExecStart=/bin/sh"}, `invalid value "infinity\nExecStart=/bin/sh" of systemd service override TasksMax: must not contain newlines`},
+		{map[string]string{"TasksMax": "infinity\r[Install]"}, `invalid value "infinity\r[Install]" of systemd service override TasksMax: must not contain newlines`},
+	} {
+		systemd := NewSystemdProvisioner("", &fakedriver.Driver{})
+		systemd.EngineOptions = engine.Options{SystemdServiceOverrides: c.overrides}
+		redhat := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+		redhat.EngineOptions = engine.Options{SystemdServiceOverrides: c.overrides}
+
+		for _, p := range []interface {
+			GenerateDockerOptions(dockerPort int) (*DockerOptions, error)
+		}{&systemd, redhat} {
+			_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+			assert.EqualError(t, err, c.err)
+		}
+	}
+}
+
+func TestSystemdGenerateDockerOptionsEnvironmentOverride(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		Env: []string{"DEBUG=1"},
+		SystemdServiceOverrides: map[string]string{
+			"Environment":  `"HTTP_PROXY=http://proxy.corp:3128" "NO_PROXY=%H"`,
+			"ExecStartPre": `/bin/sh -c "echo $HOME"`,
+		},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "Environment=\"DEBUG=1\" \n"+
+		"Environment=\"HTTP_PROXY=http://proxy.corp:3128\" \"NO_PROXY=%H\"\n"+
+		"ExecStartPre=/bin/sh -c \"echo $HOME\"\n")
+}
+
+func TestSystemdGenerateDockerOptionsNoServiceOverrides(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "LimitCORE=infinity\nEnvironment=\n\n[Install]\n")
+}
+
+func TestSystemdGenerateDockerOptionsTLSVerify(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/test/ca-cert",
+		ServerKeyRemotePath:  "/test/server-key",
+		ServerCertRemotePath: "/test/server-cert",
+	}
+	p.EngineOptions = engine.Options{
+		StorageDriver: "overlay",
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--storage-driver overlay --tlsverify --tlscacert /test/ca-cert --tlscert /test/server-cert --tlskey /test/server-key ")
+}
+
+func TestSystemdGenerateDockerOptionsZeroValueVerifiesTLS(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/test/ca-cert",
+		ServerKeyRemotePath:  "/test/server-key",
+		ServerCertRemotePath: "/test/server-cert",
+	}
+	p.EngineOptions = engine.Options{}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, " --tlsverify --tlscacert /test/ca-cert --tlscert /test/server-cert --tlskey /test/server-key ")
+}
+
+func TestSystemdGenerateDockerOptionsInsecure(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.AuthOptions = auth.Options{
+		CaCertRemotePath:     "/test/ca-cert",
+		ServerKeyRemotePath:  "/test/server-key",
+		ServerCertRemotePath: "/test/server-cert",
+	}
+	p.EngineOptions = engine.Options{
+		StorageDriver:    "overlay",
+		DisableTLSVerify: true,
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "-H tcp://0.0.0.0:2376 ")
+	assert.NotContains(t, dockerCfg.EngineOptions, "--tlsverify")
+	assert.NotContains(t, dockerCfg.EngineOptions, "/test/ca-cert")
+	assert.NotContains(t, dockerCfg.EngineOptions, "/test/server-cert")
+	assert.NotContains(t, dockerCfg.EngineOptions, "/test/server-key")
+}
+
+func TestSystemdGenerateDockerOptionsListenAddress(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		ListenAddress: "10.0.0.5",
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "-H tcp://10.0.0.5:2376 ")
+	assert.NotContains(t, dockerCfg.EngineOptions, "0.0.0.0")
+}
+
+func TestSystemdGenerateDockerOptionsIPv6ListenAddress(t *testing.T) {
+	for _, listenAddress := range []string{"fe80::1", "[fe80::1]"} {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.EngineOptions = engine.Options{
+			ListenAddress: listenAddress,
+		}
+
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Contains(t, dockerCfg.EngineOptions, "-H tcp://[fe80::1]:2376 ")
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDefaultListenAddress(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "-H tcp://0.0.0.0:2376 ")
+}
+
+func TestSystemdGenerateDockerOptionsStorageOpts(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		StorageDriver: "overlay2",
+		StorageOpts:   []string{"overlay2.size=20G", "overlay2.override_kernel_check=true"},
+		TLSVerify:     true,
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--storage-driver overlay2 --storage-opt overlay2.size=20G --storage-opt overlay2.override_kernel_check=true --tlsverify ")
+}
+
+func TestSystemdGenerateDockerOptionsProviderLabelOnce(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.NoError(t, err)
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"provider=Driver"}, p.EngineOptions.Labels)
+	assert.Equal(t, 1, strings.Count(dockerCfg.EngineOptions, "--label provider="))
+}
+
+func TestSystemdGenerateDockerOptionsMountFlags(t *testing.T) {
+	shared := "shared"
+	empty := ""
+
+	cases := []struct {
+		mountFlags *string
+		expected   string
+	}{
+		{nil, "\nMountFlags=slave\nLimitNOFILE=1048576\n"},
+		{&shared, "\nMountFlags=shared\nLimitNOFILE=1048576\n"},
+		{&empty, "\nLimitNOFILE=1048576\n"},
+	}
+
+	for _, c := range cases {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.EngineOptions = engine.Options{MountFlags: c.mountFlags}
+
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Contains(t, dockerCfg.EngineOptions, c.expected)
+		if c.mountFlags != nil && *c.mountFlags == "" {
+			assert.NotContains(t, dockerCfg.EngineOptions, "MountFlags")
+		}
+	}
+}
+
+func TestGenerateDockerOptionsInvalidMountFlags(t *testing.T) {
+	mountFlags := "foo"
+
+	systemd := NewSystemdProvisioner("", &fakedriver.Driver{})
+	systemd.EngineOptions = engine.Options{MountFlags: &mountFlags}
+	redhat := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	redhat.EngineOptions = engine.Options{MountFlags: &mountFlags}
+
+	for _, p := range []interface {
+		GenerateDockerOptions(dockerPort int) (*DockerOptions, error)
+	}{&systemd, redhat} {
+		_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.EqualError(t, err, `invalid mount flags "foo": expected one of shared, slave, private, unbindable or empty`)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDaemonBinary(t *testing.T) {
+	cases := []struct {
+		daemonBinary string
+		expected     string
+	}{
+		{"", "ExecStart=/usr/bin/docker daemon -H "},
+		{"/usr/local/bin/docker", "ExecStart=/usr/local/bin/docker daemon -H "},
+		{"/usr/bin/dockerd", "ExecStart=/usr/bin/dockerd -H "},
+		{"/usr/bin/docker -d", "ExecStart=/usr/bin/docker -d -H "},
+	}
+
+	for _, c := range cases {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.EngineOptions = engine.Options{DaemonBinary: c.daemonBinary}
+
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Contains(t, dockerCfg.EngineOptions, c.expected)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDockerVersion(t *testing.T) {
+	cases := []struct {
+		dockerVersion string
+		pinnedVersion string
+		execStart     string
+	}{
+		{"1.7.1", "", "ExecStart=/usr/bin/docker -d -H tcp://"},
+		{"1.11.0-rc2", "", "ExecStart=/usr/bin/docker daemon -H tcp://"},
+		{"1.12.0", "", "ExecStart=/usr/bin/dockerd -H tcp://"},
+		{"17.03.0-ce", "1.11", "ExecStart=/usr/bin/dockerd -H tcp://"},
+		{"", "1.11", "ExecStart=/usr/bin/docker daemon -H tcp://"},
+		{"", "17.03", "ExecStart=/usr/bin/dockerd -H tcp://"},
+		{"", "latest", "ExecStart=/usr/bin/docker daemon -H tcp://"},
+		{"", "", "ExecStart=/usr/bin/docker daemon -H tcp://"},
+	}
+
+	for _, c := range cases {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		sshCmder := &recordingSSHCommander{}
+		p.SSHCommander = sshCmder
+		p.DockerVersion = c.dockerVersion
+		p.EngineOptions = engine.Options{Version: c.pinnedVersion}
+
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Contains(t, dockerCfg.EngineOptions, c.execStart)
+		assert.Empty(t, sshCmder.commands)
+	}
+}
+
+func TestWriteDockerOptionsDetectsDockerVersion(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	sshCmder := &cannedSSHCommander{
+		responses: map[string]string{"docker --version": "Docker version 1.11.0-rc2, build 3b7a9e9\n"},
+	}
+	p.SSHCommander = sshCmder
+
+	fallback := &FallbackProvisioner{SystemdProvisioner: p}
+
+	_, err := writeDockerOptions(fallback, engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1.11.0-rc2", fallback.DockerVersion)
+	assert.Equal(t, "docker --version", sshCmder.commands[0])
+	assert.Contains(t, sshCmder.commands[2], "ExecStart=/usr/bin/docker daemon -H tcp://")
+}
+
+func TestSystemdGenerateDockerOptionsDataRoot(t *testing.T) {
+	cases := []struct {
+		dockerVersion string
+		flag          string
+		jsonKey       string
+		otherJSONKey  string
+	}{
+		{"17.03.0-ce", "--graph /mnt/docker ", "graph", "data-root"},
+		{"17.06.0-ce", "--data-root /mnt/docker ", "data-root", "graph"},
+	}
+
+	for _, c := range cases {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.DockerVersion = c.dockerVersion
+		p.EngineOptions = engine.Options{DataRoot: "/mnt/docker"}
+
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Contains(t, dockerCfg.EngineOptions, c.flag)
+
+		p.EngineOptions = engine.Options{DataRoot: "/mnt/docker", UseDaemonJSON: true}
+
+		dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		var config map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+		assert.Equal(t, "/mnt/docker", config[c.jsonKey])
+		assert.NotContains(t, config, c.otherJSONKey)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsRelativeDataRoot(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{DataRoot: "mnt/docker"}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.EqualError(t, err, `invalid data root "mnt/docker": must be an absolute path`)
+}
+
+func TestSystemdGenerateDockerOptionsRelativeDaemonBinary(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{DaemonBinary: "dockerd"}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.EqualError(t, err, `invalid daemon binary "dockerd": must be an absolute path`)
+}
+
+func TestSystemdGenerateDockerOptionsExtraHosts(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{ExtraHosts: []string{"tcp://127.0.0.1:2378"}, StorageDriver: "overlay2"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:2376 -H unix:///var/run/docker.sock -H tcp://127.0.0.1:2378 --storage-driver overlay2 ")
+}
+
+func TestGenerateDockerOptionsInvalidExtraHosts(t *testing.T) {
+	for _, host := range []string{"127.0.0.1:2378", "tcp://127.0.0.1", "tcp://127.0.0.1:99999", "unix://docker.sock", "ssh://host:22"} {
+		p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+		p.EngineOptions = engine.Options{ExtraHosts: []string{host}}
+
+		_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.EqualError(t, err, fmt.Sprintf("invalid extra host %q: expected tcp://host:port, unix:///path or fd://", host))
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDaemonJSON(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.AuthOptions = auth.Options{CaCertRemotePath: "/etc/docker/ca.pem"}
+	p.EngineOptions = engine.Options{
+		UseDaemonJSON:    true,
+		StorageDriver:    "overlay",
+		Labels:           []string{"env=test"},
+		InsecureRegistry: []string{"registry.local:5000"},
+		ArbitraryFlags:   []string{"debug"},
+		TLSVerify:        true,
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nExecStart=/usr/bin/dockerd --debug\n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "--storage-driver")
+	assert.Equal(t, "/etc/docker/daemon.json", dockerCfg.DaemonJSONPath)
+
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, []interface{}{"env=test", "provider=Driver"}, config["labels"])
+	assert.Equal(t, []interface{}{"registry.local:5000"}, config["insecure-registries"])
+	assert.Equal(t, []interface{}{"tcp://0.0.0.0:2376", "unix:///var/run/docker.sock"}, config["hosts"])
+	assert.Equal(t, "overlay", config["storage-driver"])
+	assert.Equal(t, true, config["tlsverify"])
+	assert.Equal(t, "/etc/docker/ca.pem", config["tlscacert"])
+	assert.NotContains(t, config, "registry-mirrors")
+}
+
+func TestSystemdGenerateDockerOptionsRuntimes(t *testing.T) {
+	engineOptions := engine.Options{
+		Runtimes:       map[string]string{"nvidia": "/usr/bin/nvidia-container-runtime"},
+		DefaultRuntime: "nvidia",
+	}
+
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engineOptions
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--add-runtime nvidia=/usr/bin/nvidia-container-runtime --default-runtime nvidia ")
+
+	p.EngineOptions = engineOptions
+	p.EngineOptions.UseDaemonJSON = true
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, dockerCfg.EngineOptions, "--add-runtime")
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, map[string]interface{}{"nvidia": map[string]interface{}{"path": "/usr/bin/nvidia-container-runtime"}}, config["runtimes"])
+	assert.Equal(t, "nvidia", config["default-runtime"])
+}
+
+func TestSystemdGenerateDockerOptionsLogDriver(t *testing.T) {
+	engineOptions := engine.Options{
+		LogDriver: "journald",
+		LogOpts:   map[string]string{"tag": "{{.Name}}"},
+	}
+
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engineOptions
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--log-driver journald --log-opt tag={{.Name}} ")
+
+	p.EngineOptions = engineOptions
+	p.EngineOptions.UseDaemonJSON = true
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, "journald", config["log-driver"])
+	assert.Equal(t, map[string]interface{}{"tag": "{{.Name}}"}, config["log-opts"])
+}
+
+func TestSystemdGenerateDockerOptionsNoLogDriver(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, dockerCfg.EngineOptions, "--log-")
+}
+
+func TestSystemdGenerateDockerOptionsCgroupDriver(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{CgroupDriver: "systemd"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--exec-opt native.cgroupdriver=systemd ")
+
+	p.EngineOptions = engine.Options{CgroupDriver: "systemd", UseDaemonJSON: true}
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, []interface{}{"native.cgroupdriver=systemd"}, config["exec-opts"])
+}
+
+func TestSystemdGenerateDockerOptionsLiveRestore(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{LiveRestore: true}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--live-restore ")
+
+	p.EngineOptions = engine.Options{LiveRestore: true, UseDaemonJSON: true}
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, true, config["live-restore"])
+}
+
+func TestSystemdGenerateDockerOptionsUsernsRemap(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{StorageDriver: "overlay", UsernsRemap: "default"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--userns-remap=default ")
+
+	p.EngineOptions = engine.Options{StorageDriver: "overlay", UsernsRemap: "default", UseDaemonJSON: true}
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, "default", config["userns-remap"])
+}
+
+func TestSystemdGenerateDockerOptionsUsernsRemapStorageDriverConflict(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{StorageDriver: "btrfs", UsernsRemap: "default"}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.EqualError(t, err, "userns-remap cannot be used with the btrfs storage driver")
+}
+
+func TestSystemdGenerateDockerOptionsInvalidUsernsRemap(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{UsernsRemap: "dockremap; reboot"}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.EqualError(t, err, `invalid userns-remap "dockremap; reboot": must be default or a user[:group]`)
+}
+
+func TestSystemdGenerateDockerOptionsBridgeNetworks(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		StorageDriver:       "overlay",
+		BridgeIP:            "192.168.200.1/24",
+		DefaultAddressPools: []string{"base=10.10.0.0/16,size=24"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--bip 192.168.200.1/24 --default-address-pool base=10.10.0.0/16,size=24 ")
+
+	p.EngineOptions.UseDaemonJSON = true
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, "192.168.200.1/24", config["bip"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"base": "10.10.0.0/16", "size": float64(24)}}, config["default-address-pools"])
+}
+
+func TestSystemdGenerateDockerOptionsInvalidBridgeNetworks(t *testing.T) {
+	for _, c := range []struct {
+		engineOptions engine.Options
+		err           string
+	}{
+		{engine.Options{BridgeIP: "192.168.200.1"}, `invalid bridge IP "192.168.200.1": must be an address with a netmask, e.g. 192.168.200.1/24`},
+		{engine.Options{DefaultAddressPools: []string{"10.10.0.0/16"}}, `invalid default address pool "10.10.0.0/16": must be base=<cidr>,size=<n>`},
+		{engine.Options{DefaultAddressPools: []string{"base=10.10.0.0,size=24"}}, `invalid default address pool "base=10.10.0.0,size=24": base must be a CIDR`},
+		{engine.Options{DefaultAddressPools: []string{"base=10.10.0.0/16,size=8"}}, `invalid default address pool "base=10.10.0.0/16,size=8": size must be between 16 and 32`},
+		{engine.Options{DefaultAddressPools: []string{"base=10.10.0.0/16,gateway=10.10.0.1"}}, `invalid default address pool "base=10.10.0.0/16,gateway=10.10.0.1": unknown field "gateway"`},
+	} {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.EngineOptions = c.engineOptions
+
+		_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.EqualError(t, err, c.err)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDefaultUlimits(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{StorageDriver: "overlay", DefaultUlimits: map[string]string{"nofile": "1024:2048"}}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--default-ulimit nofile=1024:2048 ")
+
+	p.EngineOptions.UseDaemonJSON = true
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, map[string]interface{}{
+		"nofile": map[string]interface{}{"Name": "nofile", "Soft": float64(1024), "Hard": float64(2048)},
+	}, config["default-ulimits"])
+}
+
+func TestSystemdGenerateDockerOptionsInvalidDefaultUlimits(t *testing.T) {
+	for _, c := range []struct {
+		defaultUlimits map[string]string
+		err            string
+	}{
+		{map[string]string{"nofile": "1024"}, "invalid default ulimit nofile=1024: must be soft:hard"},
+		{map[string]string{"nofile": "soft:2048"}, "invalid default ulimit nofile=soft:2048: the soft limit must be a number"},
+		{map[string]string{"nofile": "1024:2048 --debug"}, "invalid default ulimit nofile=1024:2048 --debug: the hard limit must be a number"},
+		{map[string]string{"nofile": "2048:1024"}, "invalid default ulimit nofile=2048:1024: the soft limit exceeds the hard limit"},
+		{map[string]string{"files": "1024:2048"}, `invalid default ulimit "files": unknown ulimit`},
+	} {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.EngineOptions = engine.Options{DefaultUlimits: c.defaultUlimits}
+
+		_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.EqualError(t, err, c.err)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsInvalidRuntimes(t *testing.T) {
+	for _, engineOptions := range []engine.Options{
+		{Runtimes: map[string]string{"nvidia": "nvidia-container-runtime"}},
+		{DefaultRuntime: "nvidia"},
+	} {
+		p := NewSystemdProvisioner("", &fakedriver.Driver{})
+		p.EngineOptions = engineOptions
+
+		_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.Error(t, err)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsNoDaemonJSON(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Empty(t, dockerCfg.DaemonJSON)
+	assert.Empty(t, dockerCfg.DaemonJSONPath)
+}
+
+func TestSystemdGenerateDockerOptionsWantedBy(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{SystemdWantedBy: "network-online.target"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(dockerCfg.EngineOptions, "\n[Install]\nWantedBy=network-online.target\n"))
+}
+
+func TestSystemdGenerateDockerOptionsDefaultWantedBy(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(dockerCfg.EngineOptions, "\n[Install]\nWantedBy=multi-user.target\n"))
+}
+
+func TestSystemdGenerateDockerOptionsInvalidWantedBy(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{SystemdWantedBy: "network-online.service"}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.EqualError(t, err, `invalid systemd WantedBy "network-online.service": must be a .target unit`)
+}
+
+func TestSystemdGenerateDockerOptionsEnvFile(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		UseEnvFile: true,
+		Env:        []string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=localhost,127.0.0.1"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nEnvironmentFile=-/etc/sysconfig/docker-machine\n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "\nEnvironment=")
+	assert.Equal(t, "/etc/sysconfig/docker-machine", dockerCfg.EnvFilePath)
+	assert.Equal(t, "HTTP_PROXY=\"http://proxy:3128\"\nNO_PROXY=\"localhost,127.0.0.1\"\n", dockerCfg.EnvFile)
+}
+
+func TestRedHatGenerateDockerOptionsEnvFileDropIn(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.UseDropIn = true
+	p.EngineOptions = engine.Options{UseEnvFile: true, Env: []string{"DEBUG=1"}}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nEnvironmentFile=-/etc/sysconfig/docker-machine\n")
+	assert.Equal(t, "DEBUG=\"1\"\n", dockerCfg.EnvFile)
+}
+
+func TestSystemdGenerateDockerOptionsDropInLimits(t *testing.T) {
+	shared := "shared"
+
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.UseDropIn = true
+	p.EngineOptions = engine.Options{
+		MountFlags: &shared,
+		LimitCore:  "0",
+		Ulimits:    map[string]string{"nofile": "4194304"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nMountFlags=shared\nLimitNOFILE=4194304\nLimitCORE=0\nEnvironment=")
+	assert.NotContains(t, dockerCfg.EngineOptions, "LimitNPROC")
+}
+
+func TestSystemdGenerateDockerOptionsDropInDefaultLimits(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.UseDropIn = true
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, dockerCfg.EngineOptions, "MountFlags")
+	assert.NotContains(t, dockerCfg.EngineOptions, "Limit")
+}
+
+func TestSystemdGenerateDockerOptionsInlineEnv(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{Env: []string{"DEBUG=1"}}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nEnvironment=\"DEBUG=1\" \n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "EnvironmentFile")
+	assert.Empty(t, dockerCfg.EnvFile)
+}
+
+func TestSystemdGenerateDockerOptionsUlimits(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		Ulimits: map[string]string{"nofile": "4194304", "nproc": "infinity"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nLimitNOFILE=4194304\nLimitNPROC=infinity\nLimitCORE=infinity\n")
+}
+
+func TestSystemdGenerateDockerOptionsLimitCore(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{LimitCore: "0"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nLimitNOFILE=1048576\nLimitNPROC=1048576\nLimitCORE=0\n")
+}
+
+func TestRedHatGenerateDockerOptionsInvalidLimitCore(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{LimitCore: "unlimited"}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.EqualError(t, err, `invalid core ulimit "unlimited": must be a number or "infinity"`)
+}
+
+func TestSystemdGenerateDockerOptionsInvalidUlimits(t *testing.T) {
+	cases := []struct {
+		ulimits  map[string]string
+		expected string
+	}{
+		{map[string]string{"nofile": "unlimited"}, `invalid nofile ulimit "unlimited": must be a number or "infinity"`},
+		{map[string]string{"nproc": "-1"}, `invalid nproc ulimit "-1": must be a number or "infinity"`},
+		{map[string]string{"memlock": "1024"}, `unknown ulimit "memlock", expected one of: nofile, nproc, core`},
+	}
+
+	for _, c := range cases {
+		p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+		p.EngineOptions = engine.Options{Ulimits: c.ulimits}
+
+		_, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.EqualError(t, err, c.expected)
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDropIn(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.UseDropIn = true
+	p.EngineOptions = engine.Options{
+		StorageDriver: "overlay",
+		Env:           []string{"HTTP_PROXY=http://proxy:3128"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service.d/10-machine.conf", dockerCfg.EngineOptionsPath)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Service]\nExecStart=\nExecStart=/usr/bin/docker daemon -H tcp://0.0.0.0:2376 "))
+	assert.Contains(t, dockerCfg.EngineOptions, "\nEnvironment=\"HTTP_PROXY=http://proxy:3128\" \n")
+	assert.NotContains(t, dockerCfg.EngineOptions, "[Install]")
+	assert.NotContains(t, dockerCfg.EngineOptions, "LimitNOFILE")
+}
+
+func TestGenerateDockerOptionsCustomDaemonOptionsFile(t *testing.T) {
+	systemd := NewSystemdProvisioner("", &fakedriver.Driver{})
+	systemd.SetDaemonOptionsFile("/usr/lib/systemd/system/docker.service")
+	redhat := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	redhat.SetDaemonOptionsFile("/usr/lib/systemd/system/docker.service")
+
+	for _, p := range []interface {
+		GenerateDockerOptions(dockerPort int) (*DockerOptions, error)
+	}{&systemd, redhat} {
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/usr/lib/systemd/system/docker.service", dockerCfg.EngineOptionsPath)
+	}
+}
+
+func TestRedHatGenerateDockerOptionsDropIn(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.UseDropIn = true
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/system/docker.service.d/10-machine.conf", dockerCfg.EngineOptionsPath)
+	assert.NotContains(t, dockerCfg.EngineOptions, "[Unit]")
+}
+
+func TestGenerateDockerOptionsUnitBranding(t *testing.T) {
+	engineOptions := engine.Options{
+		UnitDescription:   "Acme Container Runtime",
+		UnitDocumentation: "https://wiki.acme.example/containers",
+	}
+
+	systemd := NewSystemdProvisioner("", &fakedriver.Driver{})
+	systemd.EngineOptions = engineOptions
+	redhat := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	redhat.EngineOptions = engineOptions
+
+	for _, p := range []interface {
+		GenerateDockerOptions(dockerPort int) (*DockerOptions, error)
+	}{&systemd, redhat} {
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Unit]\nDescription=Acme Container Runtime\nDocumentation=https://wiki.acme.example/containers\n"))
+		assert.NotContains(t, dockerCfg.EngineOptions, "Docker Application Container Engine")
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDropInUnitBranding(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.UseDropIn = true
+	p.EngineOptions = engine.Options{UnitDocumentation: "https://wiki.acme.example/containers"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Unit]\nDocumentation=\nDocumentation=https://wiki.acme.example/containers\n\n[Service]\nExecStart=\n"))
+	assert.NotContains(t, dockerCfg.EngineOptions, "Description=")
+}
+
+func TestGenerateDockerOptionsUnitDependencies(t *testing.T) {
+	engineOptions := engine.Options{
+		UnitAfter:    []string{"network.target", "var-lib-docker.mount"},
+		UnitRequires: []string{"var-lib-docker.mount"},
+	}
+
+	systemd := NewSystemdProvisioner("", &fakedriver.Driver{})
+	systemd.EngineOptions = engineOptions
+	redhat := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	redhat.EngineOptions = engineOptions
+
+	dockerCfg, err := systemd.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nAfter=network.target var-lib-docker.mount\nRequires=var-lib-docker.mount\n\n[Service]\n")
+
+	dockerCfg, err = redhat.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nAfter=network.target var-lib-docker.mount docker.socket\nRequires=var-lib-docker.mount docker.socket\n\n[Service]\n")
+}
+
+func TestRedHatGenerateDockerOptionsDefaultUnitDependencies(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "\nAfter=network.target docker.socket\nRequires=docker.socket\n\n[Service]\n")
+}
+
+func TestSystemdGenerateDockerOptionsDropInUnitDependencies(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.UseDropIn = true
+	p.EngineOptions = engine.Options{UnitAfter: []string{"remote-fs.target"}}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Unit]\nAfter=remote-fs.target\n\n[Service]\nExecStart=\n"))
+	assert.NotContains(t, dockerCfg.EngineOptions, "Requires=")
+}
+
+func TestGenerateDockerOptionsAutoLabels(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{MockName: "dev"})
+	p.EngineOptions = engine.Options{AutoLabels: true}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--label provider=Driver --label machine=dev ")
+}
+
+func TestWriteDockerOptionsCreatesDropInDir(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.UseDropIn = true
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	changed, err := writeDockerOptions(&FallbackProvisioner{SystemdProvisioner: p}, engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Len(t, sshCmder.commands, 5)
+	assert.Equal(t, "docker --version", sshCmder.commands[0])
+	assert.Equal(t, "sudo cat /etc/systemd/system/docker.service.d/10-machine.conf", sshCmder.commands[1])
+	assert.True(t, strings.HasPrefix(sshCmder.commands[2], "sudo mkdir -p /etc/systemd/system/docker.service.d && "))
+	assert.Contains(t, sshCmder.commands[2], "| sudo tee /etc/systemd/system/docker.service.d/10-machine.conf.tmp && sudo mv /etc/systemd/system/docker.service.d/10-machine.conf.tmp /etc/systemd/system/docker.service.d/10-machine.conf && ")
+	assert.Equal(t, `if sudo test -f /etc/systemd/system/docker.service; then sudo rm -f /etc/systemd/system/docker.service && echo removed; fi`, sshCmder.commands[3])
+	assert.Equal(t, `if sudo grep -qsF -- '"hosts"' /etc/docker/daemon.json; then sudo rm -f /etc/docker/daemon.json && echo removed; fi`, sshCmder.commands[4])
+}
+
+func TestSystemdGenerateDockerOptionsSocketActivation(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.SocketActivation = true
+	p.EngineOptions = engine.Options{StorageDriver: "overlay"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Unit]\nDescription=Docker Application Container Engine\nDocumentation=https://docs.docker.com\nAfter=docker.socket\nRequires=docker.socket\n\n[Service]\n"))
+	assert.Contains(t, dockerCfg.EngineOptions, "ExecStart=/usr/bin/docker daemon -H fd:// --storage-driver overlay ")
+	assert.NotContains(t, dockerCfg.EngineOptions, "tcp://")
+	assert.Equal(t, "/etc/systemd/system/docker.socket", dockerCfg.SocketOptionsPath)
+	assert.Equal(t, `[Unit]
+Description=Docker Socket for the API
+PartOf=docker.service
+
+[Socket]
+ListenStream=0.0.0.0:2376
+ListenStream=/var/run/docker.sock
+SocketMode=0660
+SocketUser=root
+SocketGroup=docker
+
+[Install]
+WantedBy=sockets.target
+`, dockerCfg.SocketOptions)
+}
+
+func TestSystemdGenerateDockerOptionsSocketGroup(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{SocketGroup: "dockerusers"}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "-H unix:///var/run/docker.sock --group dockerusers ")
+
+	p.SocketActivation = true
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, dockerCfg.EngineOptions, "--group")
+	assert.Contains(t, dockerCfg.SocketOptions, "SocketGroup=dockerusers\n")
+
+	p.SocketActivation = false
+	p.EngineOptions.UseDaemonJSON = true
+
+	dockerCfg, err = p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	var config map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(dockerCfg.DaemonJSON), &config))
+	assert.Equal(t, "dockerusers", config["group"])
+}
+
+func TestSystemdGenerateDockerOptionsNoSocketActivation(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dockerCfg.EngineOptions, "[Unit]\nDescription=Docker Application Container Engine\nDocumentation=https://docs.docker.com\n\n[Service]\n"))
+	assert.Empty(t, dockerCfg.SocketOptions)
+	assert.Empty(t, dockerCfg.SocketOptionsPath)
+}
+
+func TestWriteDockerOptionsWritesSocket(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.SocketActivation = true
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	_, err := writeDockerOptions(p, engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Len(t, sshCmder.commands, 7)
+	assert.Contains(t, sshCmder.commands[2], "| sudo tee /etc/systemd/system/docker.service.tmp && sudo mv /etc/systemd/system/docker.service.tmp /etc/systemd/system/docker.service && ")
+	assert.Equal(t, "sudo cat /etc/systemd/system/docker.socket", sshCmder.commands[3])
+	assert.Contains(t, sshCmder.commands[4], "| sudo tee /etc/systemd/system/docker.socket.tmp && sudo mv /etc/systemd/system/docker.socket.tmp /etc/systemd/system/docker.socket && ")
+}
+
+func TestWriteDockerOptionsWritesDaemonJSON(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{UseDaemonJSON: true}
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	_, err := writeDockerOptions(p, engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Len(t, sshCmder.commands, 6)
+	assert.Contains(t, sshCmder.commands[2], "ExecStart=/usr/bin/dockerd\n")
+	assert.Equal(t, "sudo cat /etc/docker/daemon.json", sshCmder.commands[3])
+	assert.True(t, strings.HasPrefix(sshCmder.commands[4], "sudo mkdir -p /etc/docker && printf '%s' '{"))
+	assert.Contains(t, sshCmder.commands[4], "}' | sudo tee /etc/docker/daemon.json.tmp && sudo mv /etc/docker/daemon.json.tmp /etc/docker/daemon.json && ")
+}
+
+func TestWriteDockerOptionsRemovesStaleDaemonJSON(t *testing.T) {
+	removeDaemonJSON := `if sudo grep -qsF -- '"hosts"' /etc/docker/daemon.json; then sudo rm -f /etc/docker/daemon.json && echo removed; fi`
+
+	for _, c := range []struct {
+		removeOutput string
+		changed      bool
+	}{
+		{"removed\n", true},
+		// a daemon.json not setting the hosts is not machine's, and left alone
+		{"", false},
+	} {
+		p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+		dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+		assert.NoError(t, err)
+
+		p.SSHCommander = &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{
+				"sudo cat /etc/systemd/system/docker.service": dockerCfg.EngineOptions,
+				`if sudo test -f /etc/systemd/system/docker.service.d/10-machine.conf; then sudo rm -f /etc/systemd/system/docker.service.d/10-machine.conf && echo removed; fi`: "",
+				removeDaemonJSON: c.removeOutput,
+			},
+		}
+
+		changed, err := writeDockerOptions(p, engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Equal(t, c.changed, changed)
+	}
+}
+
+func TestWriteDockerOptionsRemovesStaleUnit(t *testing.T) {
+	for _, c := range []struct {
+		useDropIn bool
+		written   string
+		removed   string
+	}{
+		{false, "/etc/systemd/system/docker.service", "/etc/systemd/system/docker.service.d/10-machine.conf"},
+		{true, "/etc/systemd/system/docker.service.d/10-machine.conf", "/etc/systemd/system/docker.service"},
+	} {
+		p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+		p.UseDropIn = c.useDropIn
+		sshCmder := &recordingSSHCommander{}
+		p.SSHCommander = sshCmder
+
+		_, err := writeDockerOptions(p, engine.DefaultPort)
+
+		assert.NoError(t, err)
+		assert.Contains(t, sshCmder.commands[2], fmt.Sprintf("| sudo tee %s.tmp && sudo mv %s.tmp %s && ", c.written, c.written, c.written))
+		assert.Contains(t, sshCmder.commands, fmt.Sprintf("if sudo test -f %s; then sudo rm -f %s && echo removed; fi", c.removed, c.removed))
+	}
+}
+
+func TestSystemdGetOsReleaseInfo(t *testing.T) {
+	info, err := NewOsRelease([]byte(`NAME="CentOS Linux"
+VERSION="7 (Core)"
+ID="centos"
+ID_LIKE="rhel fedora"
+VERSION_ID="7"
+`))
+	assert.NoError(t, err)
+
+	p := NewSystemdProvisioner("centos", &fakedriver.Driver{})
+	p.SetOsReleaseInfo(info)
+
+	osReleaseInfo, err := p.GetOsReleaseInfo()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "centos", osReleaseInfo.ID)
+	assert.Equal(t, "7", osReleaseInfo.VersionID)
+	assert.Equal(t, "rhel fedora", osReleaseInfo.IDLike)
+}
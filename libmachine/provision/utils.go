@@ -1,18 +1,24 @@
 package provision
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -22,36 +28,1121 @@ import (
 type DockerOptions struct {
 	EngineOptions     string
 	EngineOptionsPath string
+	// SocketOptions is the socket unit of a socket activated daemon, written
+	// to SocketOptionsPath when set.
+	SocketOptions     string
+	SocketOptionsPath string
+	// DaemonJSON is the daemon.json configuring the daemon in place of its
+	// command line flags, written to DaemonJSONPath when set.
+	DaemonJSON     string
+	DaemonJSONPath string
+	// EnvFile is the environment of the daemon, written to EnvFilePath
+	// when set.
+	EnvFile     string
+	EnvFilePath string
+	// StaleFiles are files written for other engine options, e.g. a
+	// daemon.json once the daemon is configured through flags again, which
+	// are removed so that they do not conflict with the new configuration.
+	StaleFiles []StaleFile
+}
+
+// StaleFile is a file to remove from the remote host, when it holds Marker if
+// it is set, which tells the files written by machine from the user's.
+type StaleFile struct {
+	Path   string
+	Marker string
+}
+
+var (
+	// ServiceRetryAttempts is the number of times a systemctl command is
+	// attempted before its error is returned.
+	ServiceRetryAttempts = 3
+
+	// ServiceRetryBackoff is the delay before the first retry of a failed
+	// systemctl command; it doubles after every further failure.
+	ServiceRetryBackoff = 2 * time.Second
+
+	// ServiceCommandTimeout bounds each attempt of a systemctl command.
+	ServiceCommandTimeout = 5 * time.Minute
+)
+
+// templatedEngineFlags are the daemon flags the systemd unit templates
+// always emit and which may therefore only appear once on the command line.
+var templatedEngineFlags = []string{
+	"H",
+	"host",
+	"s",
+	"storage-driver",
+	"tlsverify",
+	"tlscacert",
+	"tlscert",
+	"tlskey",
+}
+
+// engineFlagName returns the bare name of a daemon flag, e.g.
+// "storage-driver" for "--storage-driver overlay2" or "storage-driver=overlay2".
+func engineFlagName(flag string) string {
+	name := strings.TrimLeft(strings.TrimSpace(flag), "-")
+	if i := strings.IndexAny(name, "= "); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+// checkArbitraryFlags returns an error if one of the arbitrary engine flags
+// collides with a flag already emitted by the daemon configuration template.
+func checkArbitraryFlags(arbitraryFlags []string, templateFlags []string) error {
+	for _, flag := range arbitraryFlags {
+		name := engineFlagName(flag)
+		for _, templateFlag := range templateFlags {
+			if name == templateFlag {
+				return fmt.Errorf("engine option %q conflicts with the --%s flag set by the provisioner", flag, templateFlag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// detectSelinux turns on SelinuxEnabled when SELinux is enforcing on the host,
+// so that the daemon labels its containers, unless the user opted out of the
+// detection or set the flag themselves.
+func detectSelinux(p SSHCommander, engineOptions *engine.Options) {
+	if engineOptions.SelinuxEnabled || engineOptions.SkipSelinuxDetection {
+		return
+	}
+
+	for _, flag := range engineOptions.ArbitraryFlags {
+		if engineFlagName(flag) == "selinux-enabled" {
+			return
+		}
+	}
+
+	out, err := p.SSHCommand("getenforce")
+	if err != nil {
+		log.Debugf("Unable to get the SELinux status, not enabling SELinux support: %s", err)
+		return
+	}
+
+	if strings.TrimSpace(out) == "Enforcing" {
+		log.Info("SELinux is enforcing, enabling SELinux support in the daemon...")
+		engineOptions.SelinuxEnabled = true
+	}
 }
 
 func installDockerGeneric(p Provisioner, baseURL string) error {
+	if err := validateInstallURL(baseURL); err != nil {
+		return err
+	}
+
+	installScript := "sh -"
+	if version := p.GetEngineOptions().Version; version != "" {
+		if !validInstallVersionPattern.MatchString(version) {
+			return fmt.Errorf("invalid Docker version %q", version)
+		}
+		installScript = fmt.Sprintf("VERSION=%s sh -", version)
+	}
+
 	// install docker - until cloudinit we use ubuntu everywhere so we
 	// just install it using the docker repos
-	if output, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", baseURL)); err != nil {
+	if output, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | %s; fi", baseURL, installScript)); err != nil {
 		return fmt.Errorf("error installing docker: %s\n", output)
 	}
 
 	return nil
 }
 
+var validInstallVersionPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.~+-]*$`)
+
+// validateInstallURL makes sure the install script is fetched over HTTP or
+// HTTPS, rather than e.g. from a file of the host.
+func validateInstallURL(installURL string) error {
+	if installURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(installURL)
+	if err != nil {
+		return fmt.Errorf("invalid engine install URL %q: %s", installURL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid engine install URL %q: the scheme must be http or https", installURL)
+	}
+
+	return nil
+}
+
+// sshCommandWithRetry runs an SSH command, retrying with an exponential
+// backoff when it fails. Freshly booted hosts do not always have systemd
+// ready to take requests, so the first few calls may transiently fail.
+func sshCommandWithRetry(p SSHCommander, command string) (string, error) {
+	var (
+		output string
+		err    error
+		delay  = ServiceRetryBackoff
+	)
+
+	for attempt := 1; attempt <= ServiceRetryAttempts; attempt++ {
+		if output, err = SSHCommandWithTimeout(p, command, ServiceCommandTimeout); err == nil {
+			return output, nil
+		}
+
+		// The command may still be running, don't start it a second time.
+		if _, ok := err.(ErrSSHCommandTimeout); ok {
+			return output, err
+		}
+
+		if attempt < ServiceRetryAttempts {
+			log.Debugf("Command %q failed (attempt %d/%d), retrying in %s: %s", command, attempt, ServiceRetryAttempts, delay, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return output, err
+}
+
+// sshConnectionErrors are found in the errors of SSH commands which lost
+// their connection to the host, as opposed to failing on it.
+var sshConnectionErrors = []string{
+	"connection reset by peer",
+	"broken pipe",
+	"remote command exited without exit status or exit signal",
+}
+
+func isSSHConnectionError(err error) bool {
+	for _, connectionError := range sshConnectionErrors {
+		if strings.Contains(err.Error(), connectionError) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serviceCommand runs the SSH command performing action on a service. A
+// restart of the daemon can drop the SSH connection on some drivers, so a
+// restart losing its connection is run once more on a new SSH session.
+func serviceCommand(p SSHCommander, command string, action serviceaction.ServiceAction) (string, error) {
+	output, err := p.SSHCommand(command)
+	if err == nil || action != serviceaction.Restart || !isSSHConnectionError(err) {
+		return output, err
+	}
+
+	log.Debugf("Lost the SSH connection running %q, retrying on a new session: %s", command, err)
+	return p.SSHCommand(command)
+}
+
+// SSHCommandWithTimeout runs an SSH command, returning an
+// ErrSSHCommandTimeout if it does not complete within timeout. The command is
+// not interrupted on the remote host; a zero timeout waits for it forever.
+func SSHCommandWithTimeout(p SSHCommander, command string, timeout time.Duration) (string, error) {
+	if timeout == 0 {
+		return p.SSHCommand(command)
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		output, err := p.SSHCommand(command)
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-time.After(timeout):
+		return "", ErrSSHCommandTimeout{Command: command, Timeout: timeout}
+	}
+}
+
+// validateDockerPort returns an error if dockerPort cannot be listened on.
+func validateDockerPort(dockerPort int) error {
+	if dockerPort < 1 || dockerPort > 65535 {
+		return fmt.Errorf("invalid Docker port %d: must be between 1 and 65535", dockerPort)
+	}
+
+	return nil
+}
+
+// registryHostRE matches the host name part of an insecure registry.
+var registryHostRE = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-\.]*[a-zA-Z0-9])?$`)
+
+// validateInsecureRegistries returns an error naming the first entry that is
+// neither a CIDR nor a host with an optional port, which the daemon would
+// otherwise silently ignore.
+func validateInsecureRegistries(registries []string) error {
+	for _, registry := range registries {
+		if !isValidInsecureRegistry(registry) {
+			return fmt.Errorf("invalid insecure registry %q: expected a CIDR or host:port", registry)
+		}
+	}
+
+	return nil
+}
+
+func isValidInsecureRegistry(registry string) bool {
+	if strings.Contains(registry, "/") {
+		_, _, err := net.ParseCIDR(registry)
+		return err == nil
+	}
+
+	host := registry
+	if h, port, err := net.SplitHostPort(registry); err == nil {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return false
+		}
+		host = h
+	}
+
+	return net.ParseIP(host) != nil || registryHostRE.MatchString(host)
+}
+
+// validateExtraHosts returns an error naming the first entry that is not a
+// tcp://host:port, unix:///path or fd:// daemon host.
+func validateExtraHosts(hosts []string) error {
+	for _, host := range hosts {
+		if !isValidDaemonHost(host) {
+			return fmt.Errorf("invalid extra host %q: expected tcp://host:port, unix:///path or fd://", host)
+		}
+	}
+
+	return nil
+}
+
+// validateRuntimes returns an error if a runtime binary is not an absolute
+// path, or if the default runtime is neither runc nor one of the runtimes.
+func validateRuntimes(engineOptions engine.Options) error {
+	for name, runtimePath := range engineOptions.Runtimes {
+		if !path.IsAbs(runtimePath) {
+			return fmt.Errorf("invalid path %q of the %s runtime: must be an absolute path", runtimePath, name)
+		}
+	}
+
+	if defaultRuntime := engineOptions.DefaultRuntime; defaultRuntime != "" && defaultRuntime != "runc" {
+		if _, ok := engineOptions.Runtimes[defaultRuntime]; !ok {
+			return fmt.Errorf("invalid default runtime %q: must be runc or one of the engine runtimes", defaultRuntime)
+		}
+	}
+
+	return nil
+}
+
+var validUsernsRemapPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]*)?$`)
+
+// usernsIncompatibleStorageDrivers are the storage drivers the daemon cannot
+// remap the ownership of the image layers with.
+var usernsIncompatibleStorageDrivers = []string{
+	"btrfs",
+	"zfs",
+}
+
+// validateUsernsRemap returns an error if the user namespace remapping is not
+// "default" or a user and optional group, or cannot be used with the storage
+// driver.
+func validateUsernsRemap(engineOptions engine.Options) error {
+	usernsRemap := engineOptions.UsernsRemap
+	if usernsRemap == "" {
+		return nil
+	}
+
+	if !validUsernsRemapPattern.MatchString(usernsRemap) {
+		return fmt.Errorf("invalid userns-remap %q: must be default or a user[:group]", usernsRemap)
+	}
+
+	for _, driver := range usernsIncompatibleStorageDrivers {
+		if engineOptions.StorageDriver == driver {
+			return fmt.Errorf("userns-remap cannot be used with the %s storage driver", driver)
+		}
+	}
+
+	return nil
+}
+
+// daemonAddressPool is a default address pool of the daemon.json.
+type daemonAddressPool struct {
+	Base string `json:"base"`
+	Size int    `json:"size"`
+}
+
+// parseDefaultAddressPool parses a "base=CIDR,size=N" default address pool,
+// returning an error unless the base is a CIDR the subnets of size N fit in.
+func parseDefaultAddressPool(pool string) (daemonAddressPool, error) {
+	var (
+		addressPool daemonAddressPool
+		size        string
+	)
+
+	for _, field := range strings.Split(pool, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return addressPool, fmt.Errorf("invalid default address pool %q: must be base=<cidr>,size=<n>", pool)
+		}
+
+		switch parts[0] {
+		case "base":
+			addressPool.Base = parts[1]
+		case "size":
+			size = parts[1]
+		default:
+			return addressPool, fmt.Errorf("invalid default address pool %q: unknown field %q", pool, parts[0])
+		}
+	}
+
+	_, base, err := net.ParseCIDR(addressPool.Base)
+	if err != nil {
+		return addressPool, fmt.Errorf("invalid default address pool %q: base must be a CIDR", pool)
+	}
+
+	ones, bits := base.Mask.Size()
+	addressPool.Size, err = strconv.Atoi(size)
+	if err != nil || addressPool.Size < ones || addressPool.Size > bits {
+		return addressPool, fmt.Errorf("invalid default address pool %q: size must be between %d and %d", pool, ones, bits)
+	}
+
+	return addressPool, nil
+}
+
+// validateBridgeNetworks returns an error if the bridge IP is not an address
+// with a netmask in CIDR notation, or a default address pool is invalid.
+func validateBridgeNetworks(engineOptions engine.Options) error {
+	if bridgeIP := engineOptions.BridgeIP; bridgeIP != "" {
+		if _, _, err := net.ParseCIDR(bridgeIP); err != nil {
+			return fmt.Errorf("invalid bridge IP %q: must be an address with a netmask, e.g. 192.168.200.1/24", bridgeIP)
+		}
+	}
+
+	for _, pool := range engineOptions.DefaultAddressPools {
+		if _, err := parseDefaultAddressPool(pool); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// daemonUlimit is a default ulimit of the daemon.json.
+type daemonUlimit struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
+// validUlimitNames are the ulimits the daemon sets on the containers.
+var validUlimitNames = []string{
+	"core",
+	"cpu",
+	"data",
+	"fsize",
+	"locks",
+	"memlock",
+	"msgqueue",
+	"nice",
+	"nofile",
+	"nproc",
+	"rss",
+	"rtprio",
+	"rttime",
+	"sigpending",
+	"stack",
+}
+
+// parseDefaultUlimit parses the "soft:hard" limits of the ulimit name,
+// returning an error unless both are numbers and soft does not exceed hard.
+func parseDefaultUlimit(name, limits string) (daemonUlimit, error) {
+	ulimit := daemonUlimit{Name: name}
+
+	validName := false
+	for _, ulimitName := range validUlimitNames {
+		if name == ulimitName {
+			validName = true
+			break
+		}
+	}
+	if !validName {
+		return ulimit, fmt.Errorf("invalid default ulimit %q: unknown ulimit", name)
+	}
+
+	parts := strings.Split(limits, ":")
+	if len(parts) != 2 {
+		return ulimit, fmt.Errorf("invalid default ulimit %s=%s: must be soft:hard", name, limits)
+	}
+
+	var err error
+	if ulimit.Soft, err = strconv.ParseInt(parts[0], 10, 64); err != nil || ulimit.Soft < 0 {
+		return ulimit, fmt.Errorf("invalid default ulimit %s=%s: the soft limit must be a number", name, limits)
+	}
+	if ulimit.Hard, err = strconv.ParseInt(parts[1], 10, 64); err != nil || ulimit.Hard < 0 {
+		return ulimit, fmt.Errorf("invalid default ulimit %s=%s: the hard limit must be a number", name, limits)
+	}
+	if ulimit.Soft > ulimit.Hard {
+		return ulimit, fmt.Errorf("invalid default ulimit %s=%s: the soft limit exceeds the hard limit", name, limits)
+	}
+
+	return ulimit, nil
+}
+
+// validateDefaultUlimits returns an error if one of the default ulimits is
+// unknown or its limits are not soft:hard.
+func validateDefaultUlimits(engineOptions engine.Options) error {
+	for name, limits := range engineOptions.DefaultUlimits {
+		if _, err := parseDefaultUlimit(name, limits); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// templatedServiceDirectives are the [Service] directives the systemd unit
+// templates set, which an override would duplicate. Environment is not one of
+// them, as each Environment line adds to the environment of the daemon.
+var templatedServiceDirectives = []string{
+	"ExecStart",
+	"MountFlags",
+	"LimitNOFILE",
+	"LimitNPROC",
+	"LimitCORE",
+	"EnvironmentFile",
+}
+
+var validServiceDirectivePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// validateServiceOverrides returns an error if one of the systemd service
+// overrides is not a directive name, such as a section header, collides with
+// a directive already set by the unit templates, or has a value spanning
+// several lines. Values are otherwise written as they are, quotes and
+// specifiers included, for systemd to interpret them.
+func validateServiceOverrides(overrides map[string]string) error {
+	for directive, value := range overrides {
+		if strings.HasPrefix(directive, "[") {
+			return fmt.Errorf("invalid systemd service override %q: section headers cannot be added", directive)
+		}
+
+		if !validServiceDirectivePattern.MatchString(directive) {
+			return fmt.Errorf("invalid systemd service override %q: not a directive name", directive)
+		}
+
+		for _, templated := range templatedServiceDirectives {
+			if directive == templated {
+				return fmt.Errorf("systemd service override %q conflicts with the %s directive set by the provisioner", directive, templated)
+			}
+		}
+
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("invalid value %q of systemd service override %s: must not contain newlines", value, directive)
+		}
+	}
+
+	return nil
+}
+
+func isValidDaemonHost(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		h, port, err := net.SplitHostPort(u.Host)
+		if err != nil || u.Path != "" {
+			return false
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return false
+		}
+		return h == "" || net.ParseIP(h) != nil || registryHostRE.MatchString(h)
+	case "unix":
+		return u.Host == "" && path.IsAbs(u.Path)
+	case "fd":
+		return u.Path == ""
+	}
+
+	return false
+}
+
+// addMachineToNoProxy adds the machine's IP and the internal ranges to the
+// NO_PROXY of a daemon configured with a proxy, so that the traffic between
+// machines does not go through the proxy.
+func addMachineToNoProxy(p Provisioner, ip string) {
+	setter, ok := p.(optionsSetter)
+	if !ok {
+		return
+	}
+
+	engineOptions := p.GetEngineOptions()
+	noProxy := engineOptions.NoProxy
+	if noProxy == nil {
+		noProxy = engine.DefaultNoProxy
+	}
+
+	engineOptions.Env = mergeNoProxy(engineOptions.Env, append([]string{ip}, noProxy...))
+	setter.SetEngineOptions(engineOptions)
+}
+
+// mergeNoProxy returns env with the entries added to its NO_PROXY and
+// no_proxy variables, or to a new NO_PROXY if it has none. env is returned
+// unchanged when it sets no proxy.
+func mergeNoProxy(env []string, entries []string) []string {
+	proxied := false
+	noProxyIndexes := []int{}
+	for i, variable := range env {
+		switch name := strings.ToUpper(strings.SplitN(variable, "=", 2)[0]); name {
+		case "HTTP_PROXY", "HTTPS_PROXY":
+			proxied = true
+		case "NO_PROXY":
+			noProxyIndexes = append(noProxyIndexes, i)
+		}
+	}
+
+	if !proxied || len(entries) == 0 {
+		return env
+	}
+
+	merged := append([]string{}, env...)
+	if len(noProxyIndexes) == 0 {
+		return append(merged, "NO_PROXY="+strings.Join(entries, ","))
+	}
+
+	for _, i := range noProxyIndexes {
+		parts := strings.SplitN(merged[i], "=", 2)
+		values := []string{}
+		present := map[string]bool{}
+		if len(parts) == 2 {
+			for _, value := range strings.Split(parts[1], ",") {
+				if value = strings.TrimSpace(value); value != "" && !present[value] {
+					present[value] = true
+					values = append(values, value)
+				}
+			}
+		}
+
+		for _, entry := range entries {
+			if !present[entry] {
+				present[entry] = true
+				values = append(values, entry)
+			}
+		}
+
+		merged[i] = parts[0] + "=" + strings.Join(values, ",")
+	}
+
+	return merged
+}
+
+// appendDriverNameLabel adds the provider=<driver> label to the engine
+// labels unless a provider label is already present, so generating the
+// daemon options more than once does not duplicate it.
+func appendDriverNameLabel(labels []string, driverName string) []string {
+	return appendLabel(labels, "provider", driverName)
+}
+
+// appendDefaultLabels returns the engine labels along with the labels derived
+// from the driver: provider=<driver>, and machine=<name> when AutoLabels is
+// set. A label whose key is already present is left as configured.
+func appendDefaultLabels(engineOptions engine.Options, d drivers.Driver) []string {
+	labels := appendDriverNameLabel(engineOptions.Labels, d.DriverName())
+	if engineOptions.AutoLabels {
+		labels = appendLabel(labels, "machine", d.GetMachineName())
+	}
+
+	return labels
+}
+
+func appendLabel(labels []string, key, value string) []string {
+	for _, label := range labels {
+		if strings.HasPrefix(label, key+"=") {
+			return labels
+		}
+	}
+
+	return append(labels, fmt.Sprintf("%s=%s", key, value))
+}
+
+// engineListenAddress returns the address the daemon should bind its TCP
+// socket to, warning if that address cannot be reached from another host.
+// IPv6 literals are bracketed so that a port can be appended to them.
+func engineListenAddress(engineOptions engine.Options) string {
+	if engineOptions.ListenAddress == "" {
+		return engine.DefaultListenAddress
+	}
+
+	address := strings.TrimSuffix(strings.TrimPrefix(engineOptions.ListenAddress, "["), "]")
+
+	if ip := net.ParseIP(address); ip != nil && ip.IsLoopback() {
+		log.Warnf("The Docker daemon will only listen on %s, 'docker-machine env' will not be able to reach it", address)
+	}
+
+	if strings.Contains(address, ":") {
+		return "[" + address + "]"
+	}
+
+	return address
+}
+
+// dockerVersionRE matches the version in the output of docker --version, e.g.
+// "Docker version 1.11.0-rc2, build 3b7a9e9".
+var dockerVersionRE = regexp.MustCompile(`Docker version (\d+\.\d+\.\d+(-[0-9A-Za-z.\-]+)?)`)
+
+// getDockerVersion returns the version of the docker binary on the host.
+func getDockerVersion(p SSHCommander) (string, error) {
+	out, err := p.SSHCommand("docker --version")
+	if err != nil {
+		return "", fmt.Errorf("error getting the Docker version: %s", err)
+	}
+
+	match := dockerVersionRE.FindStringSubmatch(out)
+	if match == nil {
+		return "", fmt.Errorf("unable to parse the Docker version from %q", strings.TrimSpace(out))
+	}
+
+	return match[1], nil
+}
+
+// pinnedVersionRE matches the versions EngineOptions.Version may pin Docker
+// to which can be compared, e.g. "17.03" or "1.12.6".
+var pinnedVersionRE = regexp.MustCompile(`^\d+\.\d+(\.\d+)?`)
+
+// renderedDockerVersion returns the Docker version the daemon options are
+// rendered for: detected, the version found on the host, or else the one
+// engineOptions pins. It is empty when neither is known.
+func renderedDockerVersion(detected string, engineOptions engine.Options) string {
+	if detected != "" {
+		return detected
+	}
+
+	if pinnedVersionRE.MatchString(engineOptions.Version) {
+		return engineOptions.Version
+	}
+
+	return ""
+}
+
+// dockerVersionSetter is implemented by provisioners rendering the daemon
+// options for the version of Docker installed on the host.
+type dockerVersionSetter interface {
+	SetDockerVersion(version string)
+}
+
+// detectDockerVersion gives the provisioner the version of Docker installed
+// on the host, leaving it empty when it cannot be told, so that
+// GenerateDockerOptions renders the options for it without connecting to the
+// host itself.
+func detectDockerVersion(p Provisioner) {
+	setter, ok := p.(dockerVersionSetter)
+	if !ok {
+		return
+	}
+
+	version, err := getDockerVersion(p)
+	if err != nil {
+		log.Debugf("Rendering the Docker options for an unknown Docker version: %s", err)
+	}
+	setter.SetDockerVersion(version)
+}
+
+// compareDockerVersions compares the numeric parts of two Docker versions,
+// returning -1, 0 or 1 when a is older than, the same as or newer than b.
+// Suffixes such as -rc2 or -ce are ignored.
+func compareDockerVersions(a, b string) int {
+	aParts := strings.Split(strings.SplitN(a, "-", 2)[0], ".")
+	bParts := strings.Split(strings.SplitN(b, "-", 2)[0], ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+
+		switch {
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// CheckMinDockerVersion waits for the host to report its Docker version, as
+// it may still be rebooting after an upgrade, then returns an error if that
+// version is older than minVersion. An empty minVersion checks nothing.
+func CheckMinDockerVersion(p Provisioner, minVersion string) error {
+	if minVersion == "" {
+		return nil
+	}
+
+	version, err := waitForDockerVersion(p)
+	if err != nil {
+		return err
+	}
+
+	return checkMinDockerVersion(version, minVersion)
+}
+
+// CheckDockerUpgrade waits for the host to report its Docker version after an
+// upgrade and logs whether Docker itself changed from previousVersion, since
+// the upgrade may only have updated the rest of the host, e.g. its kernel. The
+// version is then checked against minVersion like CheckMinDockerVersion does.
+func CheckDockerUpgrade(p Provisioner, previousVersion, minVersion string) error {
+	version, err := waitForDockerVersion(p)
+	if err != nil {
+		if minVersion == "" {
+			log.Warnf("Unable to tell whether Docker was upgraded: %s", err)
+			return nil
+		}
+		return err
+	}
+
+	switch {
+	case previousVersion == "":
+		log.Infof("Docker is now at %s", version)
+	case version == previousVersion:
+		log.Infof("Docker unchanged at %s, only the rest of the host was upgraded", version)
+	default:
+		log.Infof("Docker upgraded from %s to %s", previousVersion, version)
+	}
+
+	return checkMinDockerVersion(version, minVersion)
+}
+
+// waitForDockerVersion waits for the host to report its Docker version, as it
+// may be rebooting to complete an upgrade.
+func waitForDockerVersion(p Provisioner) (string, error) {
+	var version string
+	if err := mcnutils.WaitFor(func() bool {
+		v, err := p.GetDockerVersion()
+		if err != nil {
+			log.Debugf("Waiting for the Docker version of the host: %s", err)
+			return false
+		}
+		version = v
+		return true
+	}); err != nil {
+		return "", fmt.Errorf("error getting the Docker version of the host: %s", err)
+	}
+
+	return version, nil
+}
+
+func checkMinDockerVersion(version, minVersion string) error {
+	if minVersion != "" && compareDockerVersions(version, minVersion) < 0 {
+		return fmt.Errorf("Docker %s is older than the required %s", version, minVersion)
+	}
+
+	return nil
+}
+
+// validMountFlags are the values systemd accepts for MountFlags, the empty
+// one leaving the directive out.
+var validMountFlags = []string{"", "shared", "slave", "private", "unbindable"}
+
+// engineMountFlags returns the MountFlags directive of the daemon's systemd
+// unit, an empty string meaning the directive is left out.
+func engineMountFlags(engineOptions engine.Options) (string, error) {
+	if engineOptions.MountFlags == nil {
+		return engine.DefaultMountFlags, nil
+	}
+
+	for _, mountFlags := range validMountFlags {
+		if *engineOptions.MountFlags == mountFlags {
+			return mountFlags, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid mount flags %q: expected one of shared, slave, private, unbindable or empty", *engineOptions.MountFlags)
+}
+
+// engineUnitDescription returns the Description of the daemon's systemd unit.
+func engineUnitDescription(engineOptions engine.Options) string {
+	if engineOptions.UnitDescription == "" {
+		return engine.DefaultUnitDescription
+	}
+
+	return engineOptions.UnitDescription
+}
+
+// engineUnitDocumentation returns the Documentation of the daemon's systemd
+// unit.
+func engineUnitDocumentation(engineOptions engine.Options) string {
+	if engineOptions.UnitDocumentation == "" {
+		return engine.DefaultUnitDocumentation
+	}
+
+	return engineOptions.UnitDocumentation
+}
+
+// engineUnitDependencies returns the After and Requires of the daemon's
+// systemd unit. defaultAfter is used when no UnitAfter is configured, and the
+// units in required are always both ordered after and required.
+func engineUnitDependencies(engineOptions engine.Options, defaultAfter []string, required ...string) (string, string) {
+	after := defaultAfter
+	if len(engineOptions.UnitAfter) > 0 {
+		after = engineOptions.UnitAfter
+	}
+
+	after = append(append([]string{}, after...), required...)
+	requires := append(append([]string{}, engineOptions.UnitRequires...), required...)
+
+	return strings.Join(after, " "), strings.Join(requires, " ")
+}
+
+// engineWantedBy returns the target the daemon's systemd unit is installed in.
+func engineWantedBy(engineOptions engine.Options) (string, error) {
+	if engineOptions.SystemdWantedBy == "" {
+		return engine.DefaultWantedBy, nil
+	}
+
+	if !strings.HasSuffix(engineOptions.SystemdWantedBy, ".target") {
+		return "", fmt.Errorf("invalid systemd WantedBy %q: must be a .target unit", engineOptions.SystemdWantedBy)
+	}
+
+	return engineOptions.SystemdWantedBy, nil
+}
+
+// engineUlimits lists the limits of the daemon's systemd unit, keyed in
+// EngineOptions.Ulimits by their ulimit name.
+var engineUlimits = []struct {
+	Name, Directive, Default string
+}{
+	{"nofile", "LimitNOFILE", "1048576"},
+	{"nproc", "LimitNPROC", "1048576"},
+	{"core", "LimitCORE", "infinity"},
+}
+
+// UnitLimit is a resource limit directive of the daemon's systemd unit.
+type UnitLimit struct {
+	Directive string
+	Value     string
+	// Configured is set when Value was configured rather than defaulted,
+	// the drop-in overriding only those of the distribution's unit.
+	Configured bool
+}
+
+// engineUnitLimits returns the limit directives of the daemon's systemd unit,
+// the defaults being replaced by the values set in EngineOptions.Ulimits, or
+// by EngineOptions.LimitCore for the core limit.
+func engineUnitLimits(engineOptions engine.Options) ([]UnitLimit, error) {
+	ulimits := map[string]string{}
+	if engineOptions.LimitCore != "" {
+		ulimits["core"] = engineOptions.LimitCore
+	}
+	for name, value := range engineOptions.Ulimits {
+		ulimits[name] = value
+	}
+
+	limits := []UnitLimit{}
+	known := map[string]bool{}
+
+	for _, ulimit := range engineUlimits {
+		known[ulimit.Name] = true
+
+		value, ok := ulimits[ulimit.Name]
+		if !ok {
+			value = ulimit.Default
+		} else if _, err := strconv.ParseUint(value, 10, 64); err != nil && value != "infinity" {
+			return nil, fmt.Errorf("invalid %s ulimit %q: must be a number or \"infinity\"", ulimit.Name, value)
+		}
+
+		limits = append(limits, UnitLimit{Directive: ulimit.Directive, Value: value, Configured: ok})
+	}
+
+	for name := range ulimits {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown ulimit %q, expected one of: nofile, nproc, core", name)
+		}
+	}
+
+	return limits, nil
+}
+
+// validateEngineOptions checks the engine options and port rendered into the
+// units of the systemd provisioners, before anything is written to the host.
+func validateEngineOptions(engineOptions engine.Options, dockerPort int) error {
+	if err := validateDockerPort(dockerPort); err != nil {
+		return err
+	}
+
+	if err := checkArbitraryFlags(engineOptions.ArbitraryFlags, templatedEngineFlags); err != nil {
+		return err
+	}
+
+	if err := validateExtraHosts(engineOptions.ExtraHosts); err != nil {
+		return err
+	}
+
+	if err := validateRuntimes(engineOptions); err != nil {
+		return err
+	}
+
+	if err := validateUsernsRemap(engineOptions); err != nil {
+		return err
+	}
+
+	if err := validateBridgeNetworks(engineOptions); err != nil {
+		return err
+	}
+
+	if err := validateDefaultUlimits(engineOptions); err != nil {
+		return err
+	}
+
+	return validateServiceOverrides(engineOptions.SystemdServiceOverrides)
+}
+
+// engineDaemonCommand returns the command the systemd unit starts the daemon
+// with. The "daemon" subcommand is appended to DaemonBinary unless it already
+// names one or points at dockerd, which needs none. dockerd is started by
+// default when the daemon is configured through daemon.json. Otherwise the
+// default command depends on version, the Docker version the unit is rendered
+// for.
+func engineDaemonCommand(version string, engineOptions engine.Options) (string, error) {
+	daemonBinary := engineOptions.DaemonBinary
+	if daemonBinary == "" {
+		if engineOptions.UseDaemonJSON {
+			return engine.DefaultDockerdBinary, nil
+		}
+		return defaultDaemonCommand(version), nil
+	}
+
+	fields := strings.Fields(daemonBinary)
+	if !path.IsAbs(fields[0]) {
+		return "", fmt.Errorf("invalid daemon binary %q: must be an absolute path", daemonBinary)
+	}
+
+	if len(fields) > 1 || path.Base(fields[0]) == "dockerd" {
+		return daemonBinary, nil
+	}
+
+	return daemonBinary + " daemon", nil
+}
+
+// defaultDaemonCommand returns the command starting the daemon of the Docker
+// version: "docker -d" before 1.8, "docker daemon" before 1.12 and dockerd
+// since. "docker daemon" is used when the version is not known.
+func defaultDaemonCommand(version string) string {
+	switch {
+	case version == "":
+		return engine.DefaultDaemonBinary + " daemon"
+	case compareDockerVersions(version, "1.8") < 0:
+		return engine.DefaultDaemonBinary + " -d"
+	case compareDockerVersions(version, "1.12") < 0:
+		return engine.DefaultDaemonBinary + " daemon"
+	default:
+		return engine.DefaultDockerdBinary
+	}
+}
+
+// engineDataRootFlag returns the daemon flag setting its data root to the
+// DataRoot of the engine options: data-root since Docker 17.05 and graph
+// before, or when the version is not known. It is empty without DataRoot.
+func engineDataRootFlag(version string, engineOptions engine.Options) (string, error) {
+	dataRoot := engineOptions.DataRoot
+	if dataRoot == "" {
+		return "", nil
+	}
+
+	if !path.IsAbs(dataRoot) {
+		return "", fmt.Errorf("invalid data root %q: must be an absolute path", dataRoot)
+	}
+
+	if version == "" || compareDockerVersions(version, "17.05") < 0 {
+		return "graph", nil
+	}
+
+	return "data-root", nil
+}
+
+// setMachineHostname sets the hostname of the host to the machine name,
+// unless SkipHostname is set.
+func setMachineHostname(p Provisioner) error {
+	if p.GetEngineOptions().SkipHostname {
+		log.Debug("Skipping setting the hostname, SkipHostname is set")
+		return nil
+	}
+
+	return p.SetHostname(p.GetDriver().GetMachineName())
+}
+
+// provisionStep runs one step of Provision, logging when it starts and ends
+// along with how long it took, so that slow steps stand out in debug output.
+func provisionStep(name string, step func() error) error {
+	log.Debugf("provision step started: step=%s", name)
+	start := time.Now()
+
+	if err := step(); err != nil {
+		log.Debugf("provision step failed: step=%s elapsed=%s err=%q", name, time.Since(start), err)
+		return err
+	}
+
+	log.Debugf("provision step finished: step=%s elapsed=%s", name, time.Since(start))
+	return nil
+}
+
+// dockerOptionsDirMode is the mode the docker options directory is created
+// with, as understood by both mkdir -m and stat -c %a.
+const dockerOptionsDirMode = "755"
+
+// makeDockerOptionsDir creates the docker options directory if it is missing.
+// Its mode is only changed when it differs from dockerOptionsDirMode, so
+// running it again when re-provisioning does nothing.
 func makeDockerOptionsDir(p Provisioner) error {
 	dockerDir := p.GetDockerOptionsDir()
-	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", dockerDir)); err != nil {
+	if _, err := p.SSHCommand(fmt.Sprintf(
+		`sudo mkdir -p -m %[2]s %[1]s && if [ "$(stat -c %%a %[1]s)" != "%[2]s" ]; then sudo chmod %[2]s %[1]s; fi`,
+		dockerDir,
+		dockerOptionsDirMode,
+	)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// ensureStorageDriverModule loads the kernel module an overlay-based storage
+// driver depends on and makes it load on boot, so the daemon does not fail to
+// start on kernels that do not load it by default.
+func ensureStorageDriverModule(p SSHCommander, storageDriver string) error {
+	if storageDriver != "overlay" && storageDriver != "overlay2" {
+		return nil
+	}
+
+	if _, err := p.SSHCommand("sudo modprobe overlay"); err != nil {
+		return fmt.Errorf("the %s storage driver requires the overlay kernel module, which could not be loaded: %s", storageDriver, err)
+	}
+
+	if _, err := p.SSHCommand("echo overlay | sudo tee /etc/modules-load.d/overlay.conf"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// setRemoteAuthOptions roots the remote cert paths at
+// AuthOptions.RemoteCertDir, falling back to the provisioner's Docker options
+// directory (/etc/docker on most distros) when it is not set.
 func setRemoteAuthOptions(p Provisioner) auth.Options {
-	dockerDir := p.GetDockerOptionsDir()
 	authOptions := p.GetAuthOptions()
+	certDir := authOptions.RemoteCertDir
+	if certDir == "" {
+		certDir = p.GetDockerOptionsDir()
+	}
 
 	// due to windows clients, we cannot use filepath.Join as the paths
 	// will be mucked on the linux hosts
-	authOptions.CaCertRemotePath = path.Join(dockerDir, "ca.pem")
-	authOptions.ServerCertRemotePath = path.Join(dockerDir, "server.pem")
-	authOptions.ServerKeyRemotePath = path.Join(dockerDir, "server-key.pem")
+	authOptions.CaCertRemotePath = path.Join(certDir, "ca.pem")
+	authOptions.ServerCertRemotePath = path.Join(certDir, "server.pem")
+	authOptions.ServerKeyRemotePath = path.Join(certDir, "server-key.pem")
 
 	return authOptions
 }
@@ -61,17 +1152,108 @@ func ConfigureAuth(p Provisioner) error {
 		err error
 	)
 
+	if err := validateInsecureRegistries(p.GetEngineOptions().InsecureRegistry); err != nil {
+		return err
+	}
+
+	preHooks, postHooks := getProvisionHooks(p)
+	if err := runProvisionHooks(p, "pre-provision", preHooks); err != nil {
+		return err
+	}
+
 	driver := p.GetDriver()
-	machineName := driver.GetMachineName()
 	authOptions := p.GetAuthOptions()
-	org := mcnutils.GetUsername() + "." + machineName
-	bits := 2048
 
 	ip, err := driver.GetIP()
 	if err != nil {
+		return fmt.Errorf("error getting machine IP: %s", err)
+	}
+
+	addMachineToNoProxy(p, ip)
+
+	if err := generateServerCert(p, authOptions, ip); err != nil {
+		return err
+	}
+
+	caChanged, err := copyRemoteCerts(p, authOptions)
+	if err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(driver)
+	if err != nil {
+		return err
+	}
+
+	optionsChanged, err := writeDockerOptions(p, dockerPort)
+	if err != nil {
+		return err
+	}
+
+	if err := writeRegistryMirrorAuth(p, p.GetEngineOptions().RegistryMirrorAuth); err != nil {
 		return err
 	}
 
+	if err := installExtraCACerts(p, p.GetEngineOptions().ExtraCACerts); err != nil {
+		return err
+	}
+
+	// The daemon only reads its options and certs when it starts. It keeps
+	// running when they did not change, its server cert being signed by the
+	// same CA as the new one.
+	restarted := caChanged || optionsChanged
+	if restarted {
+		if err := restartDockerWithNewBridge(p); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Docker configuration unchanged, not restarting the remote daemon")
+	}
+
+	if !p.GetEngineOptions().DisableTLSVerify && !isDryRun(p) {
+		addr := net.JoinHostPort(ip, strconv.Itoa(dockerPort))
+		err := checkDockerTLS(addr, authOptions)
+		if err != nil && !restarted {
+			// The server cert the daemon still uses may have been issued
+			// for a previous IP of the host.
+			log.Info("Restarting the remote daemon for it to use the new server cert...")
+			if err := restartDockerWithNewBridge(p); err != nil {
+				return err
+			}
+			err = checkDockerTLS(addr, authOptions)
+		}
+		if err != nil {
+			return fmt.Errorf("error connecting to the daemon with the generated certs: %s", err)
+		}
+	}
+
+	preloadImages(p, p.GetEngineOptions().PreloadImages)
+
+	return runProvisionHooks(p, "post-provision", postHooks)
+}
+
+// ConfigureClientBundle configures auth like ConfigureAuth, then returns the
+// auth options of the host with CaCertPath, ClientCertPath and ClientKeyPath
+// leading to the client bundle copied to the machine directory, for a Docker
+// client to be built with them right away.
+func ConfigureClientBundle(p Provisioner) (auth.Options, error) {
+	if err := ConfigureAuth(p); err != nil {
+		return auth.Options{}, err
+	}
+
+	authOptions := p.GetAuthOptions()
+	authOptions.CaCertPath = filepath.Join(authOptions.StorePath, "ca.pem")
+	authOptions.ClientCertPath = filepath.Join(authOptions.StorePath, "cert.pem")
+	authOptions.ClientKeyPath = filepath.Join(authOptions.StorePath, "key.pem")
+
+	return authOptions, nil
+}
+
+// generateServerCert copies the client certs to the machine directory, then
+// generates the server cert of the host at ip.
+func generateServerCert(p Provisioner, authOptions auth.Options, ip string) error {
+	org := mcnutils.GetUsername() + "." + p.GetDriver().GetMachineName()
+
 	log.Info("Copying certs to the local machine directory...")
 
 	if err := mcnutils.CopyFile(authOptions.CaCertPath, filepath.Join(authOptions.StorePath, "ca.pem")); err != nil {
@@ -98,96 +1280,463 @@ func ConfigureAuth(p Provisioner) error {
 
 	// TODO: Switch to passing just authOptions to this func
 	// instead of all these individual fields
-	err = cert.GenerateCert(
+	err := cert.GenerateCertWithKey(
 		hosts,
 		authOptions.ServerCertPath,
 		authOptions.ServerKeyPath,
 		authOptions.CaCertPath,
 		authOptions.CaPrivateKeyPath,
 		org,
-		bits,
+		authOptions.KeyAlgorithm,
+		authOptions.KeyBits,
 	)
 
 	if err != nil {
 		return fmt.Errorf("error generating server cert: %s", err)
 	}
 
-	if err := p.Service("docker", serviceaction.Stop); err != nil {
+	return nil
+}
+
+// restartDockerWithNewBridge stops the daemon, removes the docker0 bridge for
+// the daemon to create it again with its current options, then starts it.
+func restartDockerWithNewBridge(p Provisioner) error {
+	if err := StopDocker(p); err != nil {
 		return err
 	}
 
 	if _, err := p.SSHCommand(`if [ ! -z "$(ip link show docker0)" ]; then sudo ip link delete docker0; fi`); err != nil {
-		return err
+		return fmt.Errorf("error removing the docker0 bridge: %s", err)
 	}
 
+	return StartDocker(p)
+}
+
+// copyRemoteCerts uploads the CA cert along with the server cert and key to
+// the host. It returns whether the CA cert on the host was a different one.
+func copyRemoteCerts(p Provisioner, authOptions auth.Options) (bool, error) {
 	// upload certs and configure TLS auth
 	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("error reading CA cert: %s", err)
 	}
 
 	serverCert, err := ioutil.ReadFile(authOptions.ServerCertPath)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("error reading server cert: %s", err)
 	}
 	serverKey, err := ioutil.ReadFile(authOptions.ServerKeyPath)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("error reading server key: %s", err)
 	}
 
 	log.Info("Copying certs to the remote machine...")
 
+	if remoteCertDir := path.Dir(authOptions.CaCertRemotePath); remoteCertDir != p.GetDockerOptionsDir() {
+		if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", remoteCertDir)); err != nil {
+			return false, fmt.Errorf("error creating remote cert directory %s: %s", remoteCertDir, err)
+		}
+	}
+
+	caChanged := !remoteFileContains(p, authOptions.CaCertRemotePath, string(caCert))
+
+	// These ones are for Jessie and Mike <3 <3 <3
+	if err := copyFilesToRemote(p, []remoteFile{
+		{"CA cert", caCert, authOptions.CaCertRemotePath},
+		{"server cert", serverCert, authOptions.ServerCertRemotePath},
+		{"server key", serverKey, authOptions.ServerKeyRemotePath},
+	}); err != nil {
+		return false, err
+	}
+
+	return caChanged, nil
+}
+
+// RotateCerts regenerates the server cert from authOptions and deploys it on
+// the host along with the CA cert, then restarts the daemon for it to use
+// them. Unlike Provision, it leaves the rest of the host as it is.
+func RotateCerts(p Provisioner, authOptions auth.Options) error {
+	setter, ok := p.(optionsSetter)
+	if !ok {
+		return fmt.Errorf("the %s provisioner does not support rotating certs", p)
+	}
+
+	setter.SetAuthOptions(authOptions)
+	authOptions = setRemoteAuthOptions(p)
+	setter.SetAuthOptions(authOptions)
+
+	ip, err := p.GetDriver().GetIP()
+	if err != nil {
+		return fmt.Errorf("error getting machine IP: %s", err)
+	}
+
+	if err := generateServerCert(p, authOptions, ip); err != nil {
+		return err
+	}
+
+	if _, err := copyRemoteCerts(p, authOptions); err != nil {
+		return err
+	}
+
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
+		return err
+	}
+
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return fmt.Errorf("error restarting docker: %s", err)
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// preloadImages pulls the images on the host so that their first run does not
+// wait for them to download. A failed pull is only logged.
+func preloadImages(p SSHCommander, images []string) {
+	for _, image := range images {
+		log.Infof("Pulling %s on the remote daemon...", image)
+		if _, err := p.SSHCommand(fmt.Sprintf("sudo docker pull %s", shellQuote(image))); err != nil {
+			log.Warnf("Error pulling %s: %s", image, err)
+		}
+	}
+}
+
+// generateRegistryAuthConfig returns the config.json existingConfig, if any,
+// with the credentials of each registry mirror added to its auths. The other
+// settings and registries of existingConfig are left as they are.
+func generateRegistryAuthConfig(existingConfig []byte, mirrorAuth map[string]string) ([]byte, error) {
+	config := map[string]interface{}{}
+	if len(strings.TrimSpace(string(existingConfig))) != 0 {
+		if err := json.Unmarshal(existingConfig, &config); err != nil {
+			return nil, fmt.Errorf("error reading the existing ~/.docker/config.json: %s", err)
+		}
+	}
+
+	auths, ok := config["auths"].(map[string]interface{})
+	if !ok {
+		auths = map[string]interface{}{}
+	}
+
+	// The existing credentials are written back along with the new ones.
+	for _, auth := range auths {
+		if auth, ok := auth.(map[string]interface{}); ok {
+			if encoded, ok := auth["auth"].(string); ok {
+				log.RegisterSecret(encoded)
+			}
+		}
+	}
+
+	for mirror, credentials := range mirrorAuth {
+		if !strings.Contains(credentials, ":") {
+			return nil, fmt.Errorf("invalid credentials for registry mirror %s: expected user:password", mirror)
+		}
+		auth, ok := auths[mirror].(map[string]interface{})
+		if !ok {
+			auth = map[string]interface{}{}
+		}
+		auth["auth"] = base64.StdEncoding.EncodeToString([]byte(credentials))
+		auths[mirror] = auth
+	}
+	config["auths"] = auths
+
+	return json.MarshalIndent(config, "", "\t")
+}
+
+// writeRegistryMirrorAuth adds the registry mirror credentials to the
+// config.json of the SSH user on the remote host. The credentials are
+// redacted from the logs, and the file is only ever readable by the user.
+func writeRegistryMirrorAuth(p SSHCommander, mirrorAuth map[string]string) error {
+	if len(mirrorAuth) == 0 {
+		return nil
+	}
+
+	for _, credentials := range mirrorAuth {
+		log.RegisterSecret(credentials)
+		log.RegisterSecret(base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	existingConfig, err := p.SSHCommand("if [ -f ~/.docker/config.json ]; then cat ~/.docker/config.json; fi")
+	if err != nil {
+		return fmt.Errorf("error reading the existing ~/.docker/config.json: %s", err)
+	}
+	log.RegisterSecret(strings.TrimSpace(existingConfig))
+
+	config, err := generateRegistryAuthConfig([]byte(existingConfig), mirrorAuth)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Setting registry mirror credentials on the remote machine...")
+
+	if _, err := p.SSHCommand(fmt.Sprintf(
+		"(umask 077 && mkdir -p ~/.docker && printf '%%s' %s > ~/.docker/config.json) && chmod 600 ~/.docker/config.json",
+		shellQuote(string(config)),
+	)); err != nil {
+		return fmt.Errorf("error writing registry mirror credentials: %s", err)
+	}
+
+	return nil
+}
+
+// remoteFile is a file copied to the remote host by copyFilesToRemote.
+type remoteFile struct {
+	name       string
+	content    []byte
+	remotePath string
+}
+
+// transferFile writes content to remotePath on the remote host.
+var transferFile = func(p SSHCommander, content []byte, remotePath string) error {
 	// printf will choke if we don't pass a format string because of the
 	// dashes, so that's the reason for the '%%s'
-	certTransferCmdFmt := "printf '%%s' '%s' | sudo tee %s"
+	_, err := p.SSHCommand(fmt.Sprintf("printf '%%s' '%s' | sudo tee %s", string(content), remotePath))
+	return err
+}
 
-	// These ones are for Jessie and Mike <3 <3 <3
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(caCert), authOptions.CaCertRemotePath)); err != nil {
+// copyFilesToRemote transfers the files concurrently, as each of them costs
+// an SSH round trip. Every failed transfer is reported in the returned error.
+func copyFilesToRemote(p SSHCommander, files []remoteFile) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(files))
+	)
+
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file remoteFile) {
+			defer wg.Done()
+			if err := transferFile(p, file.content, file.remotePath); err != nil {
+				errs[i] = fmt.Errorf("error copying %s to %s: %s", file.name, file.remotePath, err)
+			}
+		}(i, file)
+	}
+	wg.Wait()
+
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// RestartDocker regenerates the daemon options, writes them to the remote
+// host, then restarts the daemon. Doing it in this order makes sure the
+// restarted daemon never keeps running with stale options. The daemon is left
+// running when its options did not change.
+func RestartDocker(p Provisioner) error {
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverCert), authOptions.ServerCertRemotePath)); err != nil {
+	changed, err := writeDockerOptions(p, dockerPort)
+	if err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverKey), authOptions.ServerKeyRemotePath)); err != nil {
+	if !changed {
+		log.Info("Docker configuration unchanged, not restarting the remote daemon")
+		return nil
+	}
+
+	if err := p.Service("docker", serviceaction.Restart); err != nil {
+		return fmt.Errorf("error restarting docker: %s", err)
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// StartDocker starts the daemon, which reloads systemd for it to pick up the
+// units written since, then waits for it to listen on its port.
+func StartDocker(p Provisioner) error {
+	dockerPort, err := getDockerPort(p.GetDriver())
+	if err != nil {
 		return err
 	}
 
-	dockerURL, err := driver.GetURL()
+	if err := p.Service("docker", serviceaction.Start); err != nil {
+		return fmt.Errorf("error starting docker: %s", err)
+	}
+
+	return WaitForDocker(p, dockerPort)
+}
+
+// StopDocker stops the daemon, then waits for it to no longer listen on its
+// port.
+func StopDocker(p Provisioner) error {
+	dockerPort, err := getDockerPort(p.GetDriver())
 	if err != nil {
 		return err
 	}
+
+	if err := p.Service("docker", serviceaction.Stop); err != nil {
+		return fmt.Errorf("error stopping docker: %s", err)
+	}
+
+	// Nothing is stopped on the remote host in dry-run mode, and systemd
+	// keeps listening on the port of a socket activated daemon.
+	if isDryRun(p) || isSocketActivated(p) {
+		return nil
+	}
+
+	daemonUp := checkDaemonUp(p, dockerPort)
+	if err := mcnutils.WaitForSpecific(func() bool { return !daemonUp() }, 10, 3*time.Second); err != nil {
+		return fmt.Errorf("Docker is still listening on port %d after being stopped", dockerPort)
+	}
+
+	return nil
+}
+
+// socketActivator is implemented by provisioners able to have the daemon get
+// its sockets from systemd.
+type socketActivator interface {
+	socketActivated() bool
+}
+
+func isSocketActivated(p Provisioner) bool {
+	sa, ok := p.(socketActivator)
+	return ok && sa.socketActivated()
+}
+
+// getDockerPort returns the port of the driver's Docker URL, or the default
+// Docker port if the URL has none.
+func getDockerPort(driver drivers.Driver) (int, error) {
+	dockerURL, err := driver.GetURL()
+	if err != nil {
+		return 0, fmt.Errorf("error getting the Docker URL: %s", err)
+	}
 	u, err := url.Parse(dockerURL)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("error parsing the Docker URL: %s", err)
 	}
 	dockerPort := engine.DefaultPort
 	parts := strings.Split(u.Host, ":")
 	if len(parts) == 2 {
 		dPort, err := strconv.Atoi(parts[1])
 		if err != nil {
-			return err
+			return 0, err
 		}
 		dockerPort = dPort
 	}
 
+	return dockerPort, nil
+}
+
+// writeDockerOptions generates the daemon options and writes them to the
+// remote host, returning whether they changed.
+func writeDockerOptions(p Provisioner, dockerPort int) (bool, error) {
+	detectDockerVersion(p)
+
 	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("error generating Docker options: %s", err)
 	}
 
 	log.Info("Setting Docker configuration on the remote daemon...")
 
-	if _, err = p.SSHCommand(fmt.Sprintf("printf %%s \"%s\" | sudo tee %s", dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath)); err != nil {
-		return err
+	return p.EnsureDockerConfig(*dkrcfg)
+}
+
+// ensureDockerConfig writes the daemon options to the remote host, skipping
+// the files which already hold them. It returns whether any file was written.
+func ensureDockerConfig(p SSHCommander, dkrcfg DockerOptions) (bool, error) {
+	var err error
+	changed := false
+
+	if !remoteFileContains(p, dkrcfg.EngineOptionsPath, dkrcfg.EngineOptions) {
+		if _, err = p.SSHCommand(writeRemoteFileCommand(printContentCommand(dkrcfg.EngineOptions), dkrcfg.EngineOptionsPath)); err != nil {
+			return false, fmt.Errorf("error writing Docker options to %s: %s", dkrcfg.EngineOptionsPath, err)
+		}
+		changed = true
 	}
 
-	if err := p.Service("docker", serviceaction.Start); err != nil {
-		return err
+	if dkrcfg.SocketOptions != "" && !remoteFileContains(p, dkrcfg.SocketOptionsPath, dkrcfg.SocketOptions) {
+		if _, err = p.SSHCommand(writeRemoteFileCommand(printContentCommand(dkrcfg.SocketOptions), dkrcfg.SocketOptionsPath)); err != nil {
+			return false, fmt.Errorf("error writing Docker socket options to %s: %s", dkrcfg.SocketOptionsPath, err)
+		}
+		changed = true
 	}
 
-	return WaitForDocker(p, dockerPort)
+	if dkrcfg.DaemonJSON != "" && !remoteFileContains(p, dkrcfg.DaemonJSONPath, dkrcfg.DaemonJSON) {
+		if _, err = p.SSHCommand(writeRemoteFileCommand(printContentCommand(dkrcfg.DaemonJSON), dkrcfg.DaemonJSONPath)); err != nil {
+			return false, fmt.Errorf("error writing Docker daemon.json to %s: %s", dkrcfg.DaemonJSONPath, err)
+		}
+		changed = true
+	}
+
+	if dkrcfg.EnvFile != "" && !remoteFileContains(p, dkrcfg.EnvFilePath, dkrcfg.EnvFile) {
+		if _, err = p.SSHCommand(writeRemoteFileCommand(printContentCommand(dkrcfg.EnvFile), dkrcfg.EnvFilePath)); err != nil {
+			return false, fmt.Errorf("error writing Docker environment to %s: %s", dkrcfg.EnvFilePath, err)
+		}
+		changed = true
+	}
+
+	for _, staleFile := range dkrcfg.StaleFiles {
+		removed, err := removeStaleFile(p, staleFile)
+		if err != nil {
+			return false, fmt.Errorf("error removing %s: %s", staleFile.Path, err)
+		}
+		changed = changed || removed
+	}
+
+	return changed, nil
+}
+
+// removeStaleFile removes the stale file from the remote host, returning
+// whether it was there to be removed.
+func removeStaleFile(p SSHCommander, staleFile StaleFile) (bool, error) {
+	condition := fmt.Sprintf("sudo test -f %s", staleFile.Path)
+	if staleFile.Marker != "" {
+		condition = fmt.Sprintf("sudo grep -qsF -- %s %s", shellQuote(staleFile.Marker), staleFile.Path)
+	}
+
+	output, err := p.SSHCommand(fmt.Sprintf("if %s; then sudo rm -f %s && echo removed; fi", condition, staleFile.Path))
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(output) == "removed", nil
+}
+
+// printContentCommand returns the shell command printing content as it is,
+// for it to be piped to writeRemoteFileCommand.
+func printContentCommand(content string) string {
+	return fmt.Sprintf("printf '%%s' %s", shellQuote(content))
+}
+
+// shellQuote single-quotes s for a shell to take it as one word, as it is.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// writeRemoteFileCommand returns the command writing the output of printCmd
+// to remotePath. It is written to a temporary file moved in place afterwards,
+// so that an interrupted write cannot leave a truncated unit behind, and the
+// SELinux context of the file is then restored on hosts having restorecon.
+func writeRemoteFileCommand(printCmd, remotePath string) string {
+	tmpPath := remotePath + ".tmp"
+	return fmt.Sprintf(
+		"sudo mkdir -p %s && %s | sudo tee %s && sudo mv %s %s && if command -v restorecon >/dev/null 2>&1; then sudo restorecon %s; fi",
+		path.Dir(remotePath), printCmd, tmpPath, tmpPath, remotePath, remotePath,
+	)
+}
+
+// remoteFileContains reports whether the file at remotePath already holds
+// content. Dry runs always report a difference so that the writes show up.
+func remoteFileContains(p SSHCommander, remotePath, content string) bool {
+	if isDryRun(p) {
+		return false
+	}
+
+	existing, err := p.SSHCommand(fmt.Sprintf("sudo cat %s", remotePath))
+	return err == nil && existing == content
 }
 
 func matchNetstatOut(reDaemonListening, netstatOut string) bool {
@@ -211,6 +1760,9 @@ func matchNetstatOut(reDaemonListening, netstatOut string) bool {
 
 func decideStorageDriver(p Provisioner, defaultDriver, suppliedDriver string) (string, error) {
 	if suppliedDriver != "" {
+		if err := validateStorageOpts(suppliedDriver, p.GetEngineOptions().StorageOpts); err != nil {
+			return "", err
+		}
 		return suppliedDriver, nil
 	}
 	bestSuitedDriver := ""
@@ -238,6 +1790,23 @@ func decideStorageDriver(p Provisioner, defaultDriver, suppliedDriver string) (s
 
 }
 
+// validateStorageOpts returns an error if storageDriver is given without the
+// storage options it needs: devicemapper must be given a thin pool, as its
+// loopback default is not fit for production.
+func validateStorageOpts(storageDriver string, storageOpts []string) error {
+	if storageDriver != "devicemapper" {
+		return nil
+	}
+
+	for _, opt := range storageOpts {
+		if strings.HasPrefix(opt, "dm.thinpooldev=") {
+			return nil
+		}
+	}
+
+	return errors.New("the devicemapper storage driver requires a thin pool: pass --engine-storage-opt dm.thinpooldev=<device>")
+}
+
 func getFilesystemType(p Provisioner, directory string) (string, error) {
 	statCommandOutput, err := p.SSHCommand("stat -f -c %T " + directory)
 	if err != nil {
@@ -264,6 +1833,11 @@ func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
 }
 
 func WaitForDocker(p Provisioner, dockerPort int) error {
+	// Nothing is started on the remote host in dry-run mode.
+	if isDryRun(p) {
+		return nil
+	}
+
 	if err := mcnutils.WaitForSpecific(checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
 		return NewErrDaemonAvailable(err)
 	}
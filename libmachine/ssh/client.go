@@ -33,6 +33,12 @@ type Client interface {
 	Wait() error
 }
 
+// StdinClient is implemented by the clients able to feed data to the
+// standard input of the command they run, keeping it off the command line.
+type StdinClient interface {
+	OutputWithStdin(command string, stdin io.Reader) (string, error)
+}
+
 type ExternalClient struct {
 	BaseArgs   []string
 	BinaryPath string
@@ -186,7 +192,26 @@ func (client *NativeClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client *NativeClient) OutputWithStdin(command string, stdin io.Reader) (string, error) {
+	session, err := client.session(command)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	output, err := session.CombinedOutput(command)
+
+	return string(output), err
+}
+
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
+	return client.OutputWithPtyAndStdin(command, nil)
+}
+
+// OutputWithPtyAndStdin runs command with a tty allocated, like
+// OutputWithPty, with stdin as its standard input.
+func (client *NativeClient) OutputWithPtyAndStdin(command string, stdin io.Reader) (string, error) {
 	session, err := client.session(command)
 	if err != nil {
 		return "", nil
@@ -211,6 +236,7 @@ func (client *NativeClient) OutputWithPty(command string) (string, error) {
 		return "", err
 	}
 
+	session.Stdin = stdin
 	output, err := session.CombinedOutput(command)
 	defer session.Close()
 
@@ -363,6 +389,14 @@ func (client *ExternalClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client *ExternalClient) OutputWithStdin(command string, stdin io.Reader) (string, error) {
+	args := append(client.BaseArgs, command)
+	cmd := getSSHCmd(client.BinaryPath, args...)
+	cmd.Stdin = stdin
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
 func (client *ExternalClient) Shell(args ...string) error {
 	args = append(client.BaseArgs, args...)
 	cmd := getSSHCmd(client.BinaryPath, args...)
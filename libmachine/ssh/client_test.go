@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -104,3 +105,16 @@ func TestNewExternalClient(t *testing.T) {
 		}
 	}
 }
+
+func TestExternalClientOutputWithStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh on windows")
+	}
+
+	client := &ExternalClient{BinaryPath: "sh", BaseArgs: []string{"-c"}}
+
+	output, err := client.OutputWithStdin("read password && echo \"got $password\"", strings.NewReader("s3cret\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "got s3cret\n", output)
+}
@@ -2,9 +2,17 @@ package swarm
 
 const (
 	DiscoveryServiceEndpoint = "https://discovery-stage.hub.docker.com/v1"
+
+	// ModeClassic runs the standalone swarm containers (the default).
+	ModeClassic = "classic"
+	// ModeSwarm joins the engine to a swarm-mode cluster.
+	ModeSwarm = "swarm-mode"
 )
 
 type Options struct {
+	// IsSwarm enables the swarm configuration of the host. The other options
+	// are ignored without it, so that users managing swarm themselves are
+	// not held up by it.
 	IsSwarm            bool
 	Address            string
 	Discovery          string
@@ -19,4 +27,14 @@ type Options struct {
 	ArbitraryJoinFlags []string
 	Env                []string
 	IsExperimental     bool
+	Mode               string
+	JoinToken          string
+	ManagerAddress     string
+	// ManagerAddresses are further swarm-mode managers, tried in turn after
+	// ManagerAddress until one of them accepts the engine.
+	ManagerAddresses []string
+	// Autolock makes a master initializing a swarm-mode cluster lock it,
+	// the managers then needing UnlockKey to be unlocked once restarted.
+	Autolock  bool
+	UnlockKey string
 }